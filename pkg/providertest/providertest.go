@@ -0,0 +1,55 @@
+// Package providertest runs the slingshot provider conformance checks from
+// the Go tests of a provider
+package providertest
+
+import (
+	"testing"
+
+	"github.com/simonswine/slingshot/pkg/slingshot"
+)
+
+// Run applies the provider image in a sandbox and fails t for every
+// conformance check that does not pass
+func Run(t *testing.T, imageName string) {
+	RunWithParameters(t, imageName, nil)
+}
+
+// RunWithParameters applies the provider image with params instead of the
+// built-in parameters
+func RunWithParameters(t *testing.T, imageName string, params *slingshot.Parameters) {
+	conformance := slingshot.NewProviderConformance(slingshot.NewSlingshot(), imageName)
+	conformance.Parameters = params
+
+	checks, err := conformance.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, check := range checks {
+		switch check.Result {
+		case slingshot.ConformanceFail:
+			t.Errorf("check '%s' failed: %s", check.Name, check.Message)
+		case slingshot.ConformanceSkip:
+			t.Logf("check '%s' skipped: %s", check.Name, check.Message)
+		default:
+			t.Logf("check '%s' passed", check.Name)
+		}
+	}
+}
+
+// ValidateDiscover fails t if the discover output of a provider does not
+// follow the provider schema, this runs without docker
+func ValidateDiscover(t *testing.T, discoverOutput string) {
+	config := &slingshot.ProviderConfig{}
+	if err := config.Parse(discoverOutput); err != nil {
+		t.Fatalf("discover output does not parse: %s", err)
+	}
+
+	failures, warnings := slingshot.SplitWarnings(config.ValidateSchema())
+	for _, warning := range warnings {
+		t.Log(warning)
+	}
+	for _, failure := range failures {
+		t.Error(failure)
+	}
+}
@@ -29,6 +29,15 @@ func (c *BaseCommand) log() *log.Entry {
 	return l
 }
 
+// interrupted returns a channel that is closed once the user interrupts
+// slingshot
+func (c *BaseCommand) interrupted() <-chan struct{} {
+	if c.provider == nil {
+		return nil
+	}
+	return c.provider.Interrupted()
+}
+
 func (c *BaseCommand) Config() *CommandConfig {
 	return c.config
 }
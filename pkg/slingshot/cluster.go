@@ -3,35 +3,57 @@ package slingshot
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path"
+	"path/filepath"
 	"regexp"
+	"strings"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/codegangsta/cli"
+	"github.com/pmezard/go-difflib/difflib"
 	"github.com/simonswine/slingshot/pkg/utils"
 	"gopkg.in/yaml.v2"
 )
 
+// ClusterVersion is the version of newly written cluster configs
+const ClusterVersion = "3"
+
+// ClusterVersionSlots clusters have exactly an infrastructure and a config
+// provider, they are migrated to stages on load
+const ClusterVersionSlots = "2"
+
+// ClusterVersionLegacy clusters accept providers that do not declare their
+// type and contract version
+const ClusterVersionLegacy = "1"
+
 type Cluster struct {
-	Name                   string
-	Version                string
-	Parameters             *Parameters        `yaml:"parameters"`
-	ProviderImageNames     map[string]*string `yaml:"providerImageNames"`
-	infrastructureProvider *InfrastructureProvider
-	configProvider         *ConfigProvider
-	slingshot              *Slingshot
+	Name       string
+	Version    string
+	Parameters *Parameters     `yaml:"parameters"`
+	Stages     []*ClusterStage `yaml:"stages"`
+
+	// provider slots of cluster config version 1 and 2, migrated to stages
+	ProviderImageNames   map[string]*string `yaml:"providerImageNames,omitempty"`
+	ProviderImageDigests map[string]string  `yaml:"providerImageDigests,omitempty"`
+
+	// RegistryAuth maps the registry of provider images to the registry in
+	// the docker config whose credentials are used to pull them
+	RegistryAuth map[string]string `yaml:"registryAuth,omitempty"`
+	PullPolicy   *string           `yaml:"pullPolicy,omitempty"`
+	LastApply    *ClusterApply     `yaml:"lastApply,omitempty"`
+	slingshot    *Slingshot
+	loadErr      error
+
+	// sshInsecureIgnoreHostKey accepts any host key of the machines
+	sshInsecureIgnoreHostKey bool
 }
 
 func NewCluster(slingshot *Slingshot) *Cluster {
 	c := &Cluster{
 		slingshot: slingshot,
-		Version:   "1",
-	}
-
-	// initialize map
-	c.ProviderImageNames = map[string]*string{
-		"infrastructure": nil,
-		"config":         nil,
+		Version:   ClusterVersion,
 	}
 
 	return c
@@ -46,43 +68,121 @@ func LoadClusterFromPath(slingshot *Slingshot, filePath string) (*Cluster, error
 
 	c := NewCluster(slingshot)
 
+	// files without version predate versioning
+	c.Version = ClusterVersionLegacy
+
 	if err = yaml.Unmarshal(yamlData, c); err != nil {
 		return nil, err
 	}
 
+	if err = c.checkVersion(); err != nil {
+		return nil, err
+	}
+
+	c.migrateStages()
+
 	return c, nil
 }
-func (c *Cluster) initProviders() (errs []error) {
-	for providerName, imageName := range c.ProviderImageNames {
-		if imageName == nil {
-			c.log().Warnf("Provider %s has no image name specified", providerName)
+
+// checkVersion ensures the cluster config can be handled by this version
+func (c *Cluster) checkVersion() error {
+	switch c.Version {
+	case ClusterVersion, ClusterVersionSlots:
+		return nil
+	case ClusterVersionLegacy:
+		c.log().Debugf("cluster config version %s, accepting providers without contract declaration", c.Version)
+		return nil
+	}
+	return fmt.Errorf("cluster config version '%s' is not supported, please upgrade %s", c.Version, AppName)
+}
+
+// legacyProviders is true for clusters created before providers had to
+// declare their type and contract version
+func (c *Cluster) legacyProviders() bool {
+	return c.Version == ClusterVersionLegacy
+}
+
+// initProviders initialises the providers of all stages and returns the
+// stages in the order they are applied
+func (c *Cluster) initProviders() (stages []*ClusterStage, errs []error) {
+	if err := ValidatePullPolicy(c.pullPolicy()); err != nil {
+		return nil, []error{err}
+	}
+
+	if errs := c.validateStages(); len(errs) > 0 {
+		return nil, errs
+	}
+	stages, err := c.stagesInOrder()
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	for _, stage := range stages {
+		if len(stage.Image) == 0 {
+			errs = append(errs, fmt.Errorf("stage %s has no image name specified", stage.Name))
+			continue
+		}
+
+		// providers are initialised once per invocation
+		if stage.provider != nil {
 			continue
 		}
-		err := c.newProvider(providerName, *imageName)
+
+		// use pinned image digest if available
+		imageReference := stage.Image
+		pinned := len(stage.Digest) > 0
+		if pinned {
+			repo, _, _ := ParseImageName(stage.Image)
+			imageReference = fmt.Sprintf("%s@%s", repo, stage.Digest)
+		}
+
+		provider, err := c.newProvider(stage, imageReference, c.pullPolicy())
 		if err != nil {
 			errs = append(errs, err)
+			continue
+		}
+		stage.provider = provider
+		stage.KubeconfigCommand = provider.HasCommand("kubeconfig")
+
+		if !pinned && len(provider.Digest()) > 0 {
+			stage.Digest = provider.Digest()
+			c.log().Infof("pinned %s provider to '%s'", stage.Name, provider.ImageName())
 		}
 	}
-	return
+	return stages, errs
 }
 
-func (c *Cluster) newProvider(providerName string, imageName string) error {
+func (c *Cluster) newProvider(stage *ClusterStage, imageName string, pullPolicy string) (*Provider, error) {
+	provider := &Provider{
+		cluster:        c,
+		pullPolicy:     pullPolicy,
+		stageType:      stage.ProviderType(),
+		legacyContract: stage.LegacyContract,
 
-	var provider *Provider
+		environmentSources: stage.Environment,
+	}
+	provider.init(stage.Name)
+	if err := provider.initImage(imageName); err != nil {
+		return provider, err
+	}
 
-	if providerName == "infrastructure" {
-		c.infrastructureProvider = &InfrastructureProvider{}
-		provider = &c.infrastructureProvider.Provider
+	if err := provider.validateConfig(); err != nil {
+		provider.Log().Error(err)
+		return provider, err
+	}
+	return provider, nil
+}
 
-	} else if providerName == "config" {
-		c.configProvider = &ConfigProvider{}
-		provider = &c.configProvider.Provider
-	} else {
-		return fmt.Errorf("provider '%s' not found", providerName)
+// pullPolicy returns the image pull policy of the invocation, the cluster
+// or the default in this order
+func (c *Cluster) pullPolicy() string {
+	if len(c.slingshot.pullPolicy) > 0 {
+		return c.slingshot.pullPolicy
+	}
+	if c.PullPolicy != nil {
+		return *c.PullPolicy
 	}
-	provider.cluster = c
-	provider.init(providerName)
-	return provider.initImage(imageName)
+	return PullPolicyIfNotPresent
 }
 
 func (c *Cluster) Validate() (errs []error) {
@@ -92,7 +192,7 @@ func (c *Cluster) Validate() (errs []error) {
 
 func (c *Cluster) validateName() (errs []error) {
 
-	if existingC, err := c.slingshot.getClusterByName(c.Name); err == nil && existingC != c {
+	if existingC, _ := c.slingshot.getClusterByName(c.Name); existingC != nil && existingC != c {
 		return []error{fmt.Errorf("cluster with the name '%s' already exists", c.Name)}
 	}
 
@@ -111,6 +211,16 @@ func (c *Cluster) validateName() (errs []error) {
 	return []error{}
 }
 
+// validateParameters logs validation warnings and returns the remaining
+// validation errors of params
+func (c *Cluster) validateParameters(params *Parameters) []error {
+	failures, warnings := SplitWarnings(params.Validate())
+	for _, warning := range warnings {
+		c.log().Warn(warning)
+	}
+	return failures
+}
+
 func (c *Cluster) configDirPath() string {
 	return path.Join(
 		c.slingshot.configDir,
@@ -125,6 +235,27 @@ func (c *Cluster) configFilePath() string {
 	)
 }
 
+func (c *Cluster) kubeconfigFilePath() string {
+	return path.Join(
+		c.configDirPath(),
+		SlingshotKubeconfigFileName,
+	)
+}
+
+func (c *Cluster) writeKubeconfig(content []byte) error {
+	if err := utils.EnsureDirectory(c.configDirPath()); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(c.kubeconfigFilePath(), content, 0600); err != nil {
+		return err
+	}
+
+	c.log().Infof("wrote kubeconfig to '%s'", c.kubeconfigFilePath())
+
+	return nil
+}
+
 func (c *Cluster) WriteConfig() error {
 	if err := utils.EnsureDirectory(c.configDirPath()); err != nil {
 		return err
@@ -135,7 +266,7 @@ func (c *Cluster) WriteConfig() error {
 		return err
 	}
 
-	ioutil.WriteFile(c.configFilePath(), yamlContents, 0600)
+	err = ioutil.WriteFile(c.configFilePath(), yamlContents, 0600)
 	if err != nil {
 		return err
 	}
@@ -156,31 +287,135 @@ func (c *Cluster) createParameters(context *cli.Context) []error {
 	paramsMain := &Parameters{}
 	paramsMain.Defaults()
 
-	sshKeyPath, err := utils.VagrantKeyPath()
-	if err != nil {
-		return []error{
-			fmt.Errorf("Error while determining vagrant ssh key path: %s", err),
+	// merge parameter files in order
+	for _, paramsPath := range context.StringSlice("parameters") {
+		paramsContent, err := ioutil.ReadFile(paramsPath)
+		if err != nil {
+			return []error{
+				fmt.Errorf("Error while reading parameters from '%s': %s", paramsPath, err),
+			}
+		}
+
+		var errs []error
+		for _, err := range paramsMain.Merge(string(paramsContent)) {
+			errs = append(errs, fmt.Errorf("Error in parameters from '%s': %s", paramsPath, err))
+		}
+		if len(errs) > 0 {
+			return errs
 		}
 	}
-	if context.IsSet("ssh-key") {
-		sshKeyPath = context.String("ssh-key")
-	}
-	sshKey, err := ioutil.ReadFile(sshKeyPath)
-	if err != nil {
-		return []error{
-			fmt.Errorf("Error while reading ssh key from '%s':  %s", sshKeyPath, err),
+
+	// use vagrant key if no key is specified
+	if context.IsSet("ssh-key") || paramsMain.General.Authentication.Ssh.PrivateKey == nil {
+		sshKeyPath, err := utils.VagrantKeyPath()
+		if err != nil {
+			return []error{
+				fmt.Errorf("Error while determining vagrant ssh key path: %s", err),
+			}
+		}
+		if context.IsSet("ssh-key") {
+			sshKeyPath = context.String("ssh-key")
 		}
+		sshKey, err := ioutil.ReadFile(sshKeyPath)
+		if err != nil {
+			return []error{
+				fmt.Errorf("Error while reading ssh key from '%s':  %s", sshKeyPath, err),
+			}
+		}
+
+		sshKeyString := string(sshKey)
+		paramsMain.General.Authentication.Ssh.PrivateKey = &sshKeyString
 	}
 
-	sshKeyString := string(sshKey)
-	paramsMain.General.Authentication.Ssh.PrivateKey = &sshKeyString
-	errs := paramsMain.Validate()
+	errs := c.validateParameters(paramsMain)
 	if len(errs) == 0 {
 		c.Parameters = paramsMain
 	}
 	return errs
 }
 
+// createStages reads the stages from the provider flags, the infrastructure
+// and config provider flags are applied before other stages
+func (c *Cluster) createStages(context *cli.Context) (errs []error) {
+	for _, providerName := range []string{"infrastructure", "config"} {
+		flagName := fmt.Sprintf("%s-provider", providerName)
+		imageName := context.String(flagName)
+		if len(imageName) == 0 {
+			continue
+		}
+		if err := c.AddStage(&ClusterStage{Name: providerName, Image: imageName}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, value := range context.StringSlice("provider") {
+		stage, err := ParseStage(value)
+		if err == nil {
+			err = c.AddStage(stage)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, value := range context.StringSlice("depends") {
+		name, depends, err := ParseStageDepends(value)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		stage := c.stageByName(name)
+		if stage == nil {
+			errs = append(errs, fmt.Errorf("no provider for stage '%s' provided, please add it with '--provider %s=image'", name, name))
+			continue
+		}
+		stage.Depends = append(stage.Depends, depends...)
+	}
+
+	for _, value := range context.StringSlice("env") {
+		stageName, name, source, err := ParseEnvironmentSource(value)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		stage := c.stageByName(stageName)
+		if stage == nil {
+			errs = append(errs, fmt.Errorf("no provider for stage '%s' provided, please add it with '--provider %s=image'", stageName, stageName))
+			continue
+		}
+		if stage.Environment == nil {
+			stage.Environment = map[string]*EnvironmentSource{}
+		}
+		stage.Environment[name] = source
+	}
+
+	// provider directories are stored absolute, later commands can run from
+	// another working directory
+	for _, stage := range c.Stages {
+		if !strings.HasPrefix(stage.Image, ProviderDirScheme) {
+			continue
+		}
+		dirPath, err := filepath.Abs(strings.TrimPrefix(stage.Image, ProviderDirScheme))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Error while resolving provider directory of stage '%s': %s", stage.Name, err))
+			continue
+		}
+		stage.Image = ProviderDirScheme + dirPath
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	if len(c.Stages) == 0 {
+		return []error{
+			fmt.Errorf("No provider provided, please use '--infrastructure-provider', '--config-provider' or '--provider'"),
+		}
+	}
+
+	return c.validateStages()
+}
+
 func (c *Cluster) Create(context *cli.Context) (errs []error) {
 
 	// read cluster name
@@ -197,16 +432,34 @@ func (c *Cluster) Create(context *cli.Context) (errs []error) {
 	}
 
 	// read provider flags
-	for providerName, _ := range c.ProviderImageNames {
-		flagName := fmt.Sprintf("%s-provider", providerName)
-		imageName := context.String(flagName)
-		c.ProviderImageNames[providerName] = &imageName
-		if len(imageName) == 0 {
-			errs = append(errs, fmt.Errorf("No value for '--%s' provided", flagName))
-			continue
+	errs = append(errs, c.createStages(context)...)
+	if len(errs) > 0 {
+		return errs
+	}
+
+	// set pull policy
+	if context.IsSet("pull-policy") {
+		pullPolicy := context.String("pull-policy")
+		if err := ValidatePullPolicy(pullPolicy); err != nil {
+			return []error{err}
 		}
+		c.PullPolicy = &pullPolicy
 	}
-	if len(errs) > 0 {
+
+	// pin registry credentials
+	for _, value := range context.StringSlice("registry-auth") {
+		registry, authRegistry, err := ParseRegistryAuth(value)
+		if err != nil {
+			return []error{err}
+		}
+		if c.RegistryAuth == nil {
+			c.RegistryAuth = map[string]string{}
+		}
+		c.RegistryAuth[registry] = authRegistry
+	}
+
+	// pin provider images by digest before the config is written
+	if _, errs := c.initProviders(); len(errs) > 0 {
 		return errs
 	}
 
@@ -216,59 +469,464 @@ func (c *Cluster) Create(context *cli.Context) (errs []error) {
 		errs = append(errs, err)
 	}
 
-	return c.apply()
+	return c.applyAndRecord()
 
 }
 
 func (c *Cluster) Apply(context *cli.Context) (errs []error) {
-	return c.apply()
+	return c.applyAndRecord()
+}
+
+// applyAndRecord runs apply and stores its outcome and the resulting
+// parameters in the cluster config
+func (c *Cluster) applyAndRecord() (errs []error) {
+	errs = c.apply()
+
+	c.LastApply = &ClusterApply{
+		Time:   time.Now().UTC(),
+		Result: ApplyResultSucceeded,
+	}
+	if len(errs) > 0 {
+		c.LastApply.Result = ApplyResultFailed
+		c.LastApply.Error = errs[0].Error()
+	}
+
+	if err := c.WriteConfig(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errs
 }
 
 func (c *Cluster) apply() (errs []error) {
 
-	errs = append(errs, c.initProviders()...)
+	stages, errs := c.initProviders()
+	if len(errs) > 0 {
+		return errs
+	}
+
+	for _, stage := range stages {
+		if isInterrupted(c.slingshot.Interrupted()) {
+			return []error{ErrInterrupted}
+		}
+
+		// every stage gets the parameters output by the stages before
+		paramsMainBytes, err := yaml.Marshal(c.Parameters)
+		if err != nil {
+			return []error{
+				fmt.Errorf("Error while writing parameters file: %s", err),
+			}
+		}
+		log.Debugf("params for %s stage:\n%s", stage.Name, paramsMainBytes)
+
+		output, err := stage.provider.RunCommand("apply", &paramsMainBytes)
+		if err != nil {
+			return []error{
+				fmt.Errorf("Error while running %s provider: %s", stage.Name, err),
+			}
+		}
+
+		// check and merge output from apply
+		if err := c.Parameters.Parse(string(output)); err != nil {
+			return []error{
+				fmt.Errorf("Error while parsing result of %s provider: %s", stage.Name, err),
+			}
+		}
+		errs = append(errs, c.validateParameters(c.Parameters)...)
+		if len(errs) > 0 {
+			return errs
+		}
+
+		// store kubeconfig if returned by provider
+		result := &struct {
+			Kubeconfig string `yaml:"kubeconfig"`
+		}{}
+		if err := yaml.Unmarshal(output, result); err == nil && len(result.Kubeconfig) > 0 {
+			if err := c.writeKubeconfig([]byte(result.Kubeconfig)); err != nil {
+				return []error{err}
+			}
+		}
+	}
+
+	return []error{}
+}
+
+func (c *Cluster) Destroy(context *cli.Context) (errs []error) {
+	errs = c.destroy()
+	if len(errs) > 0 {
+		// an interrupted destroy leaves resources behind
+		if !context.Bool("force") || isInterrupted(c.slingshot.Interrupted()) {
+			return append(errs, fmt.Errorf("keeping local state in '%s', use --force to remove it anyway", c.configDirPath()))
+		}
+		for _, err := range errs {
+			c.log().Warn(err)
+		}
+		c.log().Warn("provider destroy failed, removing local state anyway")
+	}
+
+	if err := os.RemoveAll(c.configDirPath()); err != nil {
+		return []error{
+			fmt.Errorf("Error while removing cluster config dir '%s': %s", c.configDirPath(), err),
+		}
+	}
+	c.log().Infof("removed cluster config dir '%s'", c.configDirPath())
+
+	return []error{}
+}
+
+func (c *Cluster) destroy() (errs []error) {
+	if c.loadErr != nil {
+		return []error{
+			fmt.Errorf("cluster config could not be loaded, its resources have to be removed manually: %s", c.loadErr),
+		}
+	}
+
+	stages, errs := c.initProviders()
 	if len(errs) > 0 {
 		return errs
 	}
 
-	// run infrastructure apply
 	paramsMainBytes, err := yaml.Marshal(c.Parameters)
 	if err != nil {
 		return []error{
 			fmt.Errorf("Error while writing parameters file: %s", err),
 		}
 	}
-	log.Debugf("params for infra:\n%s", paramsMainBytes)
 
-	output, err := c.infrastructureProvider.RunCommand("apply", &paramsMainBytes)
+	// run destroy in reverse order to apply
+	for pos := len(stages) - 1; pos >= 0; pos-- {
+		stage := stages[pos]
+		if isInterrupted(c.slingshot.Interrupted()) {
+			return []error{ErrInterrupted}
+		}
+		// the resources of the stage can not be torn down, the local
+		// state is kept unless forced
+		if !stage.provider.HasCommand("destroy") {
+			errs = append(errs, fmt.Errorf("%s provider does not declare a 'destroy' command, its resources have to be removed manually", stage.Name))
+			continue
+		}
+
+		_, err = stage.provider.RunCommand("destroy", &paramsMainBytes)
+		if err != nil {
+			return append(errs, fmt.Errorf("Error while running %s provider: %s", stage.Name, err))
+		}
+	}
+
+	return errs
+}
+
+func (c *Cluster) Status(context *cli.Context) (*ClusterStatus, []error) {
+	stages, errs := c.initProviders()
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	paramsMainBytes, err := yaml.Marshal(c.Parameters)
 	if err != nil {
-		return []error{
-			fmt.Errorf("Error while running infratstructure provider: %s", err),
+		return nil, []error{
+			fmt.Errorf("Error while writing parameters file: %s", err),
 		}
 	}
 
-	// check and merge output from infrastructure apply
-	c.Parameters.Parse(string(output))
-	errs = append(errs, c.Parameters.Validate()...)
+	status := NewClusterStatus(c.Name, c.Parameters.Inventory)
+
+	for _, stage := range stages {
+		provider := stage.provider
+		if !provider.HasCommand("status") {
+			status.SetProvider(stage.Name, StatusUnknown)
+			continue
+		}
+
+		output, err := provider.RunCommandReadOnly("status", &paramsMainBytes)
+		if err != nil {
+			status.SetProvider(stage.Name, "failed")
+			errs = append(errs, fmt.Errorf("Error while running %s provider: %s", stage.Name, err))
+			continue
+		}
+
+		providerStatus := &ProviderStatus{}
+		if err := providerStatus.Parse(string(output)); err != nil {
+			status.SetProvider(stage.Name, "failed")
+			errs = append(errs, fmt.Errorf("Error while parsing status of %s provider: %s", stage.Name, err))
+			continue
+		}
+
+		status.Merge(providerStatus)
+		status.SetProvider(stage.Name, "ok")
+	}
+
+	return status, errs
+}
+
+// Plan runs the plan commands of the providers and returns a diff between
+// the stored parameters and the parameters the providers would produce
+func (c *Cluster) Plan(context *cli.Context) (diff string, errs []error) {
+	stages, errs := c.initProviders()
 	if len(errs) > 0 {
-		return errs
+		return "", errs
 	}
 
-	// run config apply
-	paramsMainBytes, err = yaml.Marshal(c.Parameters)
+	paramsCurrentBytes, err := yaml.Marshal(c.Parameters)
 	if err != nil {
-		return []error{
+		return "", []error{
 			fmt.Errorf("Error while writing parameters file: %s", err),
 		}
 	}
-	log.Debugf("params after merge with output from infra:\n%s", paramsMainBytes)
 
-	output, err = c.configProvider.RunCommand("apply", &paramsMainBytes)
+	// work on a copy of the stored parameters
+	paramsPlanned := &Parameters{}
+	if err := paramsPlanned.Parse(string(paramsCurrentBytes)); err != nil {
+		return "", []error{err}
+	}
+
+	planned := 0
+	for _, stage := range stages {
+		if !stage.provider.HasCommand("plan") {
+			c.log().Warnf("%s provider does not declare a 'plan' command, its changes are not shown", stage.Name)
+			continue
+		}
+
+		paramsBytes, err := yaml.Marshal(paramsPlanned)
+		if err != nil {
+			return "", []error{
+				fmt.Errorf("Error while writing parameters file: %s", err),
+			}
+		}
+
+		output, err := stage.provider.RunCommandReadOnly("plan", &paramsBytes)
+		if err != nil {
+			return "", []error{
+				fmt.Errorf("Error while running %s provider: %s", stage.Name, err),
+			}
+		}
+
+		if err := paramsPlanned.Parse(string(output)); err != nil {
+			return "", []error{
+				fmt.Errorf("Error while parsing plan of %s provider: %s", stage.Name, err),
+			}
+		}
+		planned++
+	}
+
+	if planned == 0 {
+		return "", []error{
+			fmt.Errorf("none of the providers declares a 'plan' command, nothing has been applied"),
+		}
+	}
+
+	errs = append(errs, c.validateParameters(paramsPlanned)...)
+
+	paramsPlannedBytes, err := yaml.Marshal(paramsPlanned)
 	if err != nil {
-		return []error{
-			fmt.Errorf("Error while running infratstructure provider: %s", err),
+		return "", []error{
+			fmt.Errorf("Error while writing parameters file: %s", err),
 		}
 	}
 
-	return []error{}
+	diff, err = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(paramsCurrentBytes)),
+		B:        difflib.SplitLines(string(paramsPlannedBytes)),
+		FromFile: "current",
+		ToFile:   "planned",
+		Context:  3,
+	})
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	return diff, errs
+}
+
+// Kubeconfig returns the kubeconfig of the cluster, either by running the
+// kubeconfig command of the last stage declaring it or from the one stored
+// by apply
+func (c *Cluster) Kubeconfig(context *cli.Context) (*Kubeconfig, []error) {
+	// kubeconfig commands are run every time, so rotated credentials are
+	// picked up
+	refresh := context != nil && context.Bool("refresh")
+	for _, stage := range c.Stages {
+		refresh = refresh || stage.KubeconfigCommand
+	}
+
+	var content []byte
+	if refresh {
+		var errs []error
+		content, errs = c.fetchKubeconfig()
+		if len(errs) > 0 {
+			return nil, errs
+		}
+	}
+
+	// the kubeconfig stored by apply is read without initialising providers
+	if content == nil {
+		stored, err := ioutil.ReadFile(c.kubeconfigFilePath())
+		if err == nil {
+			content = stored
+		} else if !os.IsNotExist(err) {
+			return nil, []error{err}
+		} else if !refresh {
+			var errs []error
+			content, errs = c.fetchKubeconfig()
+			if len(errs) > 0 {
+				return nil, errs
+			}
+		}
+	}
+
+	if content == nil {
+		return nil, []error{
+			fmt.Errorf("no provider returned a kubeconfig, please run apply first"),
+		}
+	}
+
+	kubeconfig := &Kubeconfig{}
+	if err := kubeconfig.Parse(string(content)); err != nil {
+		return nil, []error{
+			fmt.Errorf("Error while parsing kubeconfig: %s", err),
+		}
+	}
+
+	return kubeconfig, []error{}
+}
+
+// fetchKubeconfig runs the kubeconfig command of the last stage declaring
+// one and stores its output, it returns no kubeconfig if no stage declares
+// the command
+func (c *Cluster) fetchKubeconfig() ([]byte, []error) {
+	stages, errs := c.initProviders()
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	var kubeconfigStage *ClusterStage
+	for _, stage := range stages {
+		if stage.provider.HasCommand("kubeconfig") {
+			kubeconfigStage = stage
+		}
+	}
+	if kubeconfigStage == nil {
+		return nil, []error{}
+	}
+
+	paramsMainBytes, err := yaml.Marshal(c.Parameters)
+	if err != nil {
+		return nil, []error{
+			fmt.Errorf("Error while writing parameters file: %s", err),
+		}
+	}
+
+	output, err := kubeconfigStage.provider.RunCommandReadOnly("kubeconfig", &paramsMainBytes)
+	if err != nil {
+		return nil, []error{
+			fmt.Errorf("Error while running %s provider: %s", kubeconfigStage.Name, err),
+		}
+	}
+	if len(output) == 0 {
+		return nil, []error{
+			fmt.Errorf("kubeconfig command of %s provider returned no result", kubeconfigStage.Name),
+		}
+	}
+
+	if err := c.writeKubeconfig(output); err != nil {
+		return nil, []error{err}
+	}
+	return output, []error{}
+}
+
+// MergeKubeconfig merges the kubeconfig of the cluster into the one at
+// filePath, using the cluster name as context name
+func (c *Cluster) MergeKubeconfig(kubeconfig *Kubeconfig, filePath string) error {
+	// unknown fields of the target are kept, it is merged as generic YAML
+	target := map[interface{}]interface{}{}
+
+	content, err := ioutil.ReadFile(filePath)
+	if err == nil {
+		if err := yaml.Unmarshal(content, &target); err != nil {
+			return fmt.Errorf("Error while parsing kubeconfig '%s': %s", filePath, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if target == nil {
+		target = map[interface{}]interface{}{}
+	}
+	if _, ok := target["apiVersion"]; !ok {
+		target["apiVersion"] = "v1"
+	}
+	if _, ok := target["kind"]; !ok {
+		target["kind"] = "Config"
+	}
+
+	if err := kubeconfig.Merge(target, c.Name); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(path.Dir(filePath), 0700); err != nil {
+		return err
+	}
+
+	yamlContents, err := yaml.Marshal(target)
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(filePath, yamlContents, 0600); err != nil {
+		return err
+	}
+
+	c.log().Infof("merged kubeconfig into '%s' as context '%s'", filePath, c.Name)
+
+	return nil
+}
+
+type ProviderUpgrade struct {
+	Provider  string
+	ImageName string
+	OldDigest string
+	NewDigest string
+}
+
+// UpgradeProviders pulls the latest images of the providers and pins the
+// cluster to their digests
+func (c *Cluster) UpgradeProviders(context *cli.Context) (upgrades []ProviderUpgrade, errs []error) {
+	for _, stage := range c.Stages {
+		if len(stage.Image) == 0 {
+			continue
+		}
+
+		// provider directories have no digest to upgrade to
+		if strings.HasPrefix(stage.Image, ProviderDirScheme) {
+			c.log().Debugf("skipping %s provider '%s', it is a directory", stage.Name, stage.Image)
+			continue
+		}
+
+		provider, err := c.newProvider(stage, stage.Image, PullPolicyAlways)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if len(provider.Digest()) == 0 {
+			errs = append(errs, fmt.Errorf("%s provider image '%s' has no digest", stage.Name, stage.Image))
+			continue
+		}
+
+		upgrades = append(upgrades, ProviderUpgrade{
+			Provider:  stage.Name,
+			ImageName: stage.Image,
+			OldDigest: stage.Digest,
+			NewDigest: provider.Digest(),
+		})
+		stage.Digest = provider.Digest()
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	if err := c.WriteConfig(); err != nil {
+		return nil, []error{err}
+	}
+
+	return upgrades, []error{}
 }
@@ -0,0 +1,126 @@
+package slingshot
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const EditErrorPrefix = "# ERROR: "
+
+// editorCommand returns the editor of the user, defaults to vi
+func editorCommand() string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if editor := os.Getenv(env); len(editor) > 0 {
+			return editor
+		}
+	}
+	return "vi"
+}
+
+func runEditor(filePath string) error {
+	editor := strings.Fields(editorCommand())
+	cmd := exec.Command(editor[0], append(editor[1:], filePath)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// stripEditErrors removes error comments added by a previous edit round
+func stripEditErrors(content string) string {
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(line, EditErrorPrefix) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func addEditErrors(content string, errs []error) string {
+	var header []string
+	for _, err := range errs {
+		header = append(header, fmt.Sprintf("%s%s", EditErrorPrefix, err))
+	}
+	return fmt.Sprintf("%s\n%s", strings.Join(header, "\n"), content)
+}
+
+// Edit opens the parameters of the cluster in an editor until they are valid
+// and writes them to the cluster config, returns true if the parameters
+// changed
+func (c *Cluster) Edit() (changed bool, errs []error) {
+	paramsBytes, err := yaml.Marshal(c.Parameters)
+	if err != nil {
+		return false, []error{err}
+	}
+	original := string(paramsBytes)
+
+	tempFile, err := ioutil.TempFile("", fmt.Sprintf("%s-%s-", AppName, c.Name))
+	if err != nil {
+		return false, []error{err}
+	}
+	tempPath := tempFile.Name()
+	tempFile.Close()
+	defer os.Remove(tempPath)
+
+	content := original
+	for {
+		if err := ioutil.WriteFile(tempPath, []byte(content), 0600); err != nil {
+			return false, []error{err}
+		}
+
+		if err := runEditor(tempPath); err != nil {
+			return false, []error{fmt.Errorf("Error while running editor: %s", err)}
+		}
+
+		editedBytes, err := ioutil.ReadFile(tempPath)
+		if err != nil {
+			return false, []error{err}
+		}
+		edited := stripEditErrors(string(editedBytes))
+
+		if len(strings.TrimSpace(edited)) == 0 {
+			return false, []error{fmt.Errorf("empty parameters, edit cancelled")}
+		}
+		if edited == original {
+			return false, []error{}
+		}
+
+		params := &Parameters{}
+		errs = params.Merge(edited)
+		if len(errs) == 0 {
+			errs = c.validateParameters(params)
+		}
+		if len(errs) == 0 {
+			c.Parameters = params
+			break
+		}
+
+		c.log().Warnf("parameters are not valid, reopening editor")
+		content = addEditErrors(edited, errs)
+	}
+
+	if err := c.WriteConfig(); err != nil {
+		return false, []error{err}
+	}
+
+	return true, []error{}
+}
+
+// confirm asks the user a yes/no question on stdin
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N]: ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
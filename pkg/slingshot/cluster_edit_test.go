@@ -0,0 +1,102 @@
+package slingshot
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// prepareEditCluster returns a cluster with valid parameters, whose
+// editor is the shell script editorScript. The script gets the file to edit
+// as $1 and its own path as $0.
+func prepareEditCluster(t *testing.T, editorScript string) (c *Cluster, scriptPath string, cleanUp func()) {
+	configDir, err := ioutil.TempDir("", AppName)
+	assert.Nil(t, err, "Unexpected error creating temp dir")
+
+	scriptPath = path.Join(configDir, "editor.sh")
+	err = ioutil.WriteFile(scriptPath, []byte(editorScript), 0644)
+	assert.Nil(t, err, "Unexpected error writing editor script")
+
+	visual, editor := os.Getenv("VISUAL"), os.Getenv("EDITOR")
+	os.Unsetenv("VISUAL")
+	os.Setenv("EDITOR", "/bin/sh "+scriptPath)
+
+	c = NewCluster(&Slingshot{configDir: configDir})
+	c.Name = "test"
+	c.Parameters, err = conformanceParameters("infrastructure")
+	assert.Nil(t, err, "Unexpected error creating parameters")
+
+	return c, scriptPath, func() {
+		os.Setenv("VISUAL", visual)
+		os.Setenv("EDITOR", editor)
+		os.RemoveAll(configDir)
+	}
+}
+
+func TestClusterEditValid(t *testing.T) {
+	c, _, cleanUp := prepareEditCluster(t, `sed -i 's/masterApiPort: 443/masterApiPort: 6443/' "$1"`)
+	defer cleanUp()
+
+	changed, errs := c.Edit()
+	assert.Empty(t, errs)
+	assert.True(t, changed)
+	assert.Equal(t, 6443, c.Parameters.General.Cluster.Kubernetes.MasterApiPort)
+
+	// the edited parameters are written to the cluster config
+	stored, err := LoadClusterFromPath(c.slingshot, c.configFilePath())
+	assert.Nil(t, err, "Unexpected error loading cluster config")
+	assert.Equal(t, 6443, stored.Parameters.General.Cluster.Kubernetes.MasterApiPort)
+}
+
+func TestClusterEditInvalid(t *testing.T) {
+	// the first round adds an unknown key, the second round keeps a copy of
+	// the file with the errors and fixes the parameters, a third round fails
+	c, scriptPath, cleanUp := prepareEditCluster(t, `
+if [ -e "$0.second" ]; then
+  exit 1
+elif [ -e "$0.first" ]; then
+  cp "$1" "$0.second"
+  sed -i -e '/^unknown:/d' -e 's/masterApiPort: 443/masterApiPort: 6443/' "$1"
+else
+  cp "$1" "$0.first"
+  echo "unknown: value" >> "$1"
+fi
+`)
+	defer cleanUp()
+
+	changed, errs := c.Edit()
+	assert.Empty(t, errs)
+	assert.True(t, changed)
+	assert.Equal(t, 6443, c.Parameters.General.Cluster.Kubernetes.MasterApiPort)
+
+	first, err := ioutil.ReadFile(scriptPath + ".first")
+	assert.Nil(t, err, "Expected the editor to run a first time")
+	assert.NotContains(t, string(first), EditErrorPrefix)
+
+	second, err := ioutil.ReadFile(scriptPath + ".second")
+	assert.Nil(t, err, "Expected the editor to be reopened")
+	assert.Contains(t, string(second), EditErrorPrefix+"unknown")
+	assert.Contains(t, string(second), "unknown: value")
+}
+
+func TestClusterEditUnchanged(t *testing.T) {
+	c, _, cleanUp := prepareEditCluster(t, `exit 0`)
+	defer cleanUp()
+
+	changed, errs := c.Edit()
+	assert.Empty(t, errs)
+	assert.False(t, changed)
+
+	// nothing is written if the parameters did not change
+	_, err := os.Stat(c.configFilePath())
+	assert.True(t, os.IsNotExist(err), "Expected no cluster config to be written")
+}
+
+func TestStripEditErrors(t *testing.T) {
+	content := addEditErrors("general: {}\n", []error{fieldError("general.cluster", "is missing")})
+	assert.Contains(t, content, EditErrorPrefix)
+	assert.Equal(t, "general: {}\n", stripEditErrors(content))
+}
@@ -0,0 +1,146 @@
+package slingshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	ApplyResultSucceeded = "succeeded"
+	ApplyResultFailed    = "failed"
+)
+
+// ClusterApply records the outcome of the last apply of a cluster
+type ClusterApply struct {
+	Time   time.Time `yaml:"time"`
+	Result string    `yaml:"result"`
+	Error  string    `yaml:"error,omitempty"`
+}
+
+type ClusterListEntry struct {
+	Name                   string     `json:"name" yaml:"name"`
+	Version                string     `json:"version" yaml:"version"`
+	InfrastructureProvider string     `json:"infrastructureProvider" yaml:"infrastructureProvider"`
+	ConfigProvider         string     `json:"configProvider" yaml:"configProvider"`
+	Stages                 []string   `json:"stages" yaml:"stages"`
+	Masters                int        `json:"masters" yaml:"masters"`
+	Workers                int        `json:"workers" yaml:"workers"`
+	Inventory              int        `json:"inventory" yaml:"inventory"`
+	LastApplyTime          *time.Time `json:"lastApplyTime,omitempty" yaml:"lastApplyTime,omitempty"`
+	LastApplyResult        string     `json:"lastApplyResult,omitempty" yaml:"lastApplyResult,omitempty"`
+	Error                  string     `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+func (c *Cluster) ListEntry() ClusterListEntry {
+	e := ClusterListEntry{
+		Name:    c.Name,
+		Version: c.Version,
+	}
+
+	if c.loadErr != nil {
+		e.Error = c.loadErr.Error()
+		return e
+	}
+
+	if stage := c.stageByName("infrastructure"); stage != nil {
+		e.InfrastructureProvider = stage.Image
+	}
+	if stage := c.stageByName("config"); stage != nil {
+		e.ConfigProvider = stage.Image
+	}
+	e.Stages = []string{}
+	for _, stage := range c.Stages {
+		e.Stages = append(e.Stages, stage.Name)
+	}
+
+	if c.Parameters != nil {
+		e.Masters = c.Parameters.General.Cluster.MachineCount("masters")
+		e.Workers = c.Parameters.General.Cluster.MachineCount("workers")
+		e.Inventory = len(c.Parameters.Inventory)
+	}
+
+	if c.LastApply != nil {
+		applyTime := c.LastApply.Time
+		e.LastApplyTime = &applyTime
+		e.LastApplyResult = c.LastApply.Result
+		if c.LastApply.Result == ApplyResultFailed && len(c.LastApply.Error) > 0 {
+			e.Error = c.LastApply.Error
+		}
+	}
+
+	return e
+}
+
+func valueOrNone(value string) string {
+	if len(value) == 0 {
+		return "-"
+	}
+	return value
+}
+
+func WriteClusterListTable(out io.Writer, entries []ClusterListEntry) error {
+	w := new(tabwriter.Writer)
+
+	// Format in tab-separated columns with a tab stop of 8.
+	w.Init(out, 0, 8, 1, '\t', 0)
+	fmt.Fprintln(w, "Cluster Name\tVersion\tInfrastructure Provider\tConfig Provider\tStages\tMasters\tWorkers\tInventory\tLast Apply\tResult\tError")
+
+	for _, e := range entries {
+		lastApply := "-"
+		if e.LastApplyTime != nil {
+			lastApply = e.LastApplyTime.Format(time.RFC3339)
+		}
+		result := e.LastApplyResult
+		if len(result) == 0 {
+			result = "-"
+		}
+		fmt.Fprintf(
+			w,
+			"%s\t%s\t%s\t%s\t%s\t%d\t%d\t%d\t%s\t%s\t%s\n",
+			e.Name,
+			e.Version,
+			valueOrNone(e.InfrastructureProvider),
+			valueOrNone(e.ConfigProvider),
+			valueOrNone(strings.Join(e.Stages, ",")),
+			e.Masters,
+			e.Workers,
+			e.Inventory,
+			lastApply,
+			result,
+			e.Error,
+		)
+	}
+
+	fmt.Fprintln(w)
+	return w.Flush()
+}
+
+func WriteClusterListJSON(out io.Writer, entries []ClusterListEntry) error {
+	if entries == nil {
+		entries = []ClusterListEntry{}
+	}
+	jsonContents, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%s\n", jsonContents)
+	return err
+}
+
+func WriteClusterListYAML(out io.Writer, entries []ClusterListEntry) error {
+	if entries == nil {
+		entries = []ClusterListEntry{}
+	}
+	yamlContents, err := yaml.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(yamlContents)
+	return err
+}
@@ -0,0 +1,66 @@
+package slingshot
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func TestClusterListEntry(t *testing.T) {
+	s := &Slingshot{}
+
+	c := NewCluster(s)
+	err := yaml.Unmarshal([]byte(`name: test
+version: "1"
+providerImageNames:
+  infrastructure: infra:latest
+  config: config:latest
+lastApply:
+  time: 2016-04-20T10:00:00Z
+  result: failed
+  error: something went wrong
+parameters:
+  general:
+    cluster:
+      machines:
+        master:
+          count: 3
+          roles:
+            - masters
+        worker:
+          count: 5
+          roles:
+            - workers
+  inventory:
+    - name: k8s-masters-1`), c)
+	assert.Nil(t, err, "Unexpected error during parsing")
+	assert.True(t, c.migrateStages())
+
+	e := c.ListEntry()
+	assert.Equal(t, "test", e.Name)
+	assert.Equal(t, "infra:latest", e.InfrastructureProvider)
+	assert.Equal(t, "config:latest", e.ConfigProvider)
+	assert.Equal(t, []string{"infrastructure", "config"}, e.Stages)
+	assert.Equal(t, 3, e.Masters)
+	assert.Equal(t, 5, e.Workers)
+	assert.Equal(t, 1, e.Inventory)
+	assert.Equal(t, time.Date(2016, 4, 20, 10, 0, 0, 0, time.UTC), *e.LastApplyTime)
+	assert.Equal(t, ApplyResultFailed, e.LastApplyResult)
+	assert.Equal(t, "something went wrong", e.Error)
+
+	broken := NewCluster(s)
+	broken.Name = "broken"
+	broken.loadErr = errors.New("yaml: line 1: did not find expected key")
+	eBroken := broken.ListEntry()
+	assert.Equal(t, "broken", eBroken.Name)
+	assert.Equal(t, "yaml: line 1: did not find expected key", eBroken.Error)
+
+	buf := new(bytes.Buffer)
+	err = WriteClusterListTable(buf, []ClusterListEntry{e, eBroken})
+	assert.Nil(t, err, "Unexpected error during writing")
+	assert.Contains(t, buf.String(), "did not find expected key")
+}
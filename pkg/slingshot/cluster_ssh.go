@@ -0,0 +1,327 @@
+package slingshot
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/simonswine/slingshot/pkg/utils"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const SshDefaultPort = 22
+const SshDefaultRole = "masters"
+
+// sshHosts selects hosts from the inventory by name, by role or the first
+// host of the masters role if target is empty
+func (c *Cluster) sshHosts(target string) (hosts []ParameterInventory, err error) {
+	if c.Parameters == nil || len(c.Parameters.Inventory) == 0 {
+		return nil, fmt.Errorf("cluster '%s' has no inventory, please run apply first", c.Name)
+	}
+
+	if len(target) == 0 {
+		target = SshDefaultRole
+	}
+
+	for _, host := range c.Parameters.Inventory {
+		if host.Name != nil && *host.Name == target {
+			return []ParameterInventory{host}, nil
+		}
+	}
+
+	for _, host := range c.Parameters.Inventory {
+		for _, role := range host.Roles {
+			if role == target {
+				hosts = append(hosts, host)
+				break
+			}
+		}
+	}
+
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no host or role matching '%s' found in inventory", target)
+	}
+
+	return hosts, nil
+}
+
+// sshKnownHostsPath returns the path of the known_hosts file of the user
+func sshKnownHostsPath() (string, error) {
+	homeDir, err := utils.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(homeDir, ".ssh", "known_hosts"), nil
+}
+
+// sshKnownHostMatches checks if a host pattern of a known_hosts entry
+// matches address, patterns can be hashed
+func sshKnownHostMatches(pattern string, address string) bool {
+	if strings.HasPrefix(pattern, "|1|") {
+		parts := strings.Split(pattern[3:], "|")
+		if len(parts) != 2 {
+			return false
+		}
+		salt, err := base64.StdEncoding.DecodeString(parts[0])
+		if err != nil {
+			return false
+		}
+		hash, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return false
+		}
+		mac := hmac.New(sha1.New, salt)
+		mac.Write([]byte(address))
+		return hmac.Equal(mac.Sum(nil), hash)
+	}
+
+	return pattern == address
+}
+
+// sshHostKeyCallback verifies host keys against the key recorded in the
+// inventory or the known_hosts file
+func (c *Cluster) sshHostKeyCallback(host ParameterInventory, knownHostsPath string) func(string, net.Addr, ssh.PublicKey) error {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if host.HostKey != nil {
+			expected, _, _, _, err := ssh.ParseAuthorizedKey([]byte(*host.HostKey))
+			if err != nil {
+				return fmt.Errorf("Error while parsing host key of inventory: %s", err)
+			}
+			if !bytes.Equal(expected.Marshal(), key.Marshal()) {
+				return fmt.Errorf("host key of '%s' does not match the host key in the inventory", hostname)
+			}
+			return nil
+		}
+
+		address := hostname
+		if ip, port, err := net.SplitHostPort(hostname); err == nil {
+			address = ip
+			if port != fmt.Sprintf("%d", SshDefaultPort) {
+				address = fmt.Sprintf("[%s]:%s", ip, port)
+			}
+		}
+
+		content, err := ioutil.ReadFile(knownHostsPath)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("Error while reading '%s': %s", knownHostsPath, err)
+		}
+
+		known := false
+		for len(content) > 0 {
+			marker, patterns, knownKey, _, rest, err := ssh.ParseKnownHosts(content)
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return fmt.Errorf("Error while parsing '%s': %s", knownHostsPath, err)
+			}
+			content = rest
+
+			matches := false
+			for _, pattern := range patterns {
+				if sshKnownHostMatches(pattern, address) {
+					matches = true
+				}
+			}
+			if !matches || marker == "cert-authority" || knownKey.Type() != key.Type() {
+				continue
+			}
+
+			sameKey := bytes.Equal(knownKey.Marshal(), key.Marshal())
+			if marker == "revoked" {
+				if sameKey {
+					return fmt.Errorf("host key of '%s' has been revoked in '%s'", address, knownHostsPath)
+				}
+				continue
+			}
+			if sameKey {
+				return nil
+			}
+			known = true
+		}
+
+		if known {
+			return fmt.Errorf("host key of '%s' does not match the key in '%s', the host may be impersonated", address, knownHostsPath)
+		}
+		return fmt.Errorf("host key of '%s' is unknown, please add it to '%s' (e.g. with ssh-keyscan) or use --insecure-ignore-host-key", address, knownHostsPath)
+	}
+}
+
+func (c *Cluster) sshClientConfig(host ParameterInventory) (*ssh.ClientConfig, error) {
+	auth := c.Parameters.General.Authentication.Ssh
+	if auth.PrivateKey == nil {
+		return nil, fmt.Errorf("cluster '%s' has no ssh private key", c.Name)
+	}
+
+	signer, err := ssh.ParsePrivateKey([]byte(*auth.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("Error while parsing ssh private key: %s", err)
+	}
+
+	user := "root"
+	if auth.User != nil {
+		user = *auth.User
+	}
+
+	config := &ssh.ClientConfig{
+		User: user,
+		Auth: []ssh.AuthMethod{
+			ssh.PublicKeys(signer),
+		},
+	}
+
+	if c.sshInsecureIgnoreHostKey {
+		c.log().Warn("host key checking is disabled")
+		return config, nil
+	}
+
+	knownHostsPath, err := sshKnownHostsPath()
+	if err != nil {
+		return nil, fmt.Errorf("Error while determining known_hosts path: %s", err)
+	}
+	config.HostKeyCallback = c.sshHostKeyCallback(host, knownHostsPath)
+
+	return config, nil
+}
+
+func (c *Cluster) sshDial(host ParameterInventory) (*ssh.Client, error) {
+	config, err := c.sshClientConfig(host)
+	if err != nil {
+		return nil, err
+	}
+
+	var address string
+	if host.PublicIP != nil {
+		address = *host.PublicIP
+	} else if host.PrivateIP != nil {
+		address = *host.PrivateIP
+	} else {
+		return nil, fmt.Errorf("host has no IP address")
+	}
+
+	address = net.JoinHostPort(address, fmt.Sprintf("%d", SshDefaultPort))
+	c.log().Debugf("connecting to '%s' as user '%s'", address, config.User)
+
+	return ssh.Dial("tcp", address, config)
+}
+
+// SshInteractive opens an interactive shell on the host, or runs command if
+// it is not empty
+func (c *Cluster) SshInteractive(host ParameterInventory, command string) error {
+	client, err := c.sshDial(host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	session.Stdout = os.Stdout
+	session.Stderr = os.Stderr
+	session.Stdin = os.Stdin
+
+	if len(command) > 0 {
+		return session.Run(command)
+	}
+
+	fd := int(os.Stdin.Fd())
+	if terminal.IsTerminal(fd) {
+		oldState, err := terminal.MakeRaw(fd)
+		if err != nil {
+			return err
+		}
+		defer terminal.Restore(fd, oldState)
+
+		width, height, err := terminal.GetSize(fd)
+		if err != nil {
+			width, height = 80, 24
+		}
+
+		term := os.Getenv("TERM")
+		if len(term) == 0 {
+			term = "xterm"
+		}
+
+		err = session.RequestPty(term, height, width, ssh.TerminalModes{
+			ssh.ECHO: 1,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := session.Shell(); err != nil {
+		return err
+	}
+
+	return session.Wait()
+}
+
+// SshRun runs command on all hosts in parallel, prefixing the output of each
+// host with its name
+func (c *Cluster) SshRun(hosts []ParameterInventory, command string) (errs []error) {
+	var wg sync.WaitGroup
+	var errsMutex sync.Mutex
+	outputMutex := &sync.Mutex{}
+
+	for _, host := range hosts {
+		name := "unknown"
+		if host.Name != nil {
+			name = *host.Name
+		}
+
+		wg.Add(1)
+		go func(host ParameterInventory, name string) {
+			defer wg.Done()
+
+			stdout := utils.NewPrefixWriter(os.Stdout, fmt.Sprintf("%s: ", name), outputMutex)
+			stderr := utils.NewPrefixWriter(os.Stderr, fmt.Sprintf("%s: ", name), outputMutex)
+
+			err := c.sshRunSingle(host, command, stdout, stderr)
+
+			stdout.Flush()
+			stderr.Flush()
+
+			if err != nil {
+				errsMutex.Lock()
+				errs = append(errs, fmt.Errorf("host '%s': %s", name, err))
+				errsMutex.Unlock()
+			}
+		}(host, name)
+	}
+
+	wg.Wait()
+	return errs
+}
+
+func (c *Cluster) sshRunSingle(host ParameterInventory, command string, stdout, stderr *utils.PrefixWriter) error {
+	client, err := c.sshDial(host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	session.Stdout = stdout
+	session.Stderr = stderr
+
+	return session.Run(command)
+}
@@ -0,0 +1,67 @@
+package slingshot
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func sshTestHostKey(t *testing.T) ssh.PublicKey {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.Nil(t, err, "Unexpected error generating key")
+	publicKey, err := ssh.NewPublicKey(&key.PublicKey)
+	assert.Nil(t, err, "Unexpected error converting key")
+	return publicKey
+}
+
+func TestClusterSshHostKeyCallback(t *testing.T) {
+	hostKey := sshTestHostKey(t)
+	otherKey := sshTestHostKey(t)
+	authorizedKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(hostKey)))
+	remote := &net.TCPAddr{IP: net.ParseIP("10.0.0.10"), Port: 22}
+
+	knownHosts, err := ioutil.TempFile("", AppName)
+	assert.Nil(t, err, "Unexpected error creating temp file")
+	defer os.Remove(knownHosts.Name())
+
+	salt := []byte("0123456789abcdefghij")
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte("10.0.0.11"))
+	hashed := fmt.Sprintf("|1|%s|%s", base64.StdEncoding.EncodeToString(salt), base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+
+	fmt.Fprintf(knownHosts, "10.0.0.10,master-1 %s\n", authorizedKey)
+	fmt.Fprintf(knownHosts, "%s %s\n", hashed, authorizedKey)
+	knownHosts.Close()
+
+	c := NewCluster(&Slingshot{})
+	callback := c.sshHostKeyCallback(ParameterInventory{}, knownHosts.Name())
+
+	assert.Nil(t, callback("10.0.0.10:22", remote, hostKey))
+	assert.Nil(t, callback("10.0.0.11:22", remote, hostKey))
+
+	err = callback("10.0.0.10:22", remote, otherKey)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "does not match")
+	}
+
+	err = callback("10.0.0.12:22", remote, hostKey)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "is unknown")
+	}
+
+	// a host key recorded in the inventory takes precedence
+	callback = c.sshHostKeyCallback(ParameterInventory{HostKey: &authorizedKey}, knownHosts.Name())
+	assert.Nil(t, callback("10.0.0.12:22", remote, hostKey))
+	assert.NotNil(t, callback("10.0.0.10:22", remote, otherKey))
+}
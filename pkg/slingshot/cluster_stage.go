@@ -0,0 +1,216 @@
+package slingshot
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var stageNameRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// ClusterStage is a provider applied as one step of a cluster, it gets the
+// parameters output by the stages applied before it
+type ClusterStage struct {
+	Name    string   `yaml:"name"`
+	Type    string   `yaml:"type,omitempty"`
+	Image   string   `yaml:"image"`
+	Digest  string   `yaml:"digest,omitempty"`
+	Depends []string `yaml:"depends,omitempty"`
+
+	// Environment tells where the values of environment variables required
+	// by the provider are read from
+	Environment map[string]*EnvironmentSource `yaml:"environment,omitempty"`
+
+	// LegacyContract accepts providers that do not declare their type and
+	// contract version, it is set for stages migrated from legacy clusters
+	LegacyContract bool `yaml:"legacyContract,omitempty"`
+
+	// KubeconfigCommand is set if the provider declares a kubeconfig
+	// command, the kubeconfig is then fetched again every time it is read
+	KubeconfigCommand bool `yaml:"kubeconfigCommand,omitempty"`
+
+	provider *Provider
+}
+
+// ProviderType is the type the provider of the stage has to declare,
+// defaults to the name of the stage
+func (s *ClusterStage) ProviderType() string {
+	if len(s.Type) > 0 {
+		return s.Type
+	}
+	return s.Name
+}
+
+// ParseStage parses a stage given as name=image
+func ParseStage(value string) (*ClusterStage, error) {
+	pos := strings.Index(value, "=")
+	if pos < 0 {
+		return nil, fmt.Errorf("provider '%s' is not in the format name=image", value)
+	}
+
+	stage := &ClusterStage{
+		Name:  value[:pos],
+		Image: value[pos+1:],
+	}
+	if !stageNameRegexp.MatchString(stage.Name) {
+		return nil, fmt.Errorf("stage name '%s' did not match '%s'", stage.Name, stageNameRegexp)
+	}
+	if len(stage.Image) == 0 {
+		return nil, fmt.Errorf("no image for stage '%s' provided", stage.Name)
+	}
+	return stage, nil
+}
+
+// ParseStageDepends parses the dependencies of a stage given as
+// name=stage[,stage]
+func ParseStageDepends(value string) (name string, depends []string, err error) {
+	pos := strings.Index(value, "=")
+	if pos < 0 {
+		return "", nil, fmt.Errorf("dependency '%s' is not in the format name=stage[,stage]", value)
+	}
+
+	for _, depend := range strings.Split(value[pos+1:], ",") {
+		if depend = strings.TrimSpace(depend); len(depend) > 0 {
+			depends = append(depends, depend)
+		}
+	}
+	return value[:pos], depends, nil
+}
+
+func (c *Cluster) stageByName(name string) *ClusterStage {
+	for _, stage := range c.Stages {
+		if stage.Name == name {
+			return stage
+		}
+	}
+	return nil
+}
+
+// AddStage appends stage to the stages of the cluster
+func (c *Cluster) AddStage(stage *ClusterStage) error {
+	if c.stageByName(stage.Name) != nil {
+		return fmt.Errorf("stage '%s' is specified more than once", stage.Name)
+	}
+	c.Stages = append(c.Stages, stage)
+	return nil
+}
+
+// validateStages checks names and dependencies of the stages
+func (c *Cluster) validateStages() (errs []error) {
+	if len(c.Stages) == 0 {
+		return []error{fmt.Errorf("cluster has no provider stages")}
+	}
+
+	seen := map[string]bool{}
+	for pos, stage := range c.Stages {
+		path := fmt.Sprintf("stages[%d]", pos)
+		if !stageNameRegexp.MatchString(stage.Name) {
+			errs = append(errs, fieldError(path+".name", "stage name '%s' did not match '%s'", stage.Name, stageNameRegexp))
+		}
+		if seen[stage.Name] {
+			errs = append(errs, fieldError(path+".name", "stage '%s' is specified more than once", stage.Name))
+		}
+		seen[stage.Name] = true
+
+		for _, depend := range stage.Depends {
+			if depend == stage.Name {
+				errs = append(errs, fieldError(path+".depends", "stage '%s' depends on itself", stage.Name))
+			} else if c.stageByName(depend) == nil {
+				errs = append(errs, fieldError(path+".depends", "stage '%s' depends on unknown stage '%s'", stage.Name, depend))
+			}
+		}
+
+		for name, source := range stage.Environment {
+			envPath := fmt.Sprintf("%s.environment.%s", path, name)
+			if !environmentNameRegexp.MatchString(name) {
+				errs = append(errs, fieldError(envPath, "environment variable name '%s' did not match '%s'", name, environmentNameRegexp))
+			} else if source == nil {
+				errs = append(errs, fieldError(envPath, "environment variable '%s' has no source", name))
+			} else if err := source.Validate(); err != nil {
+				errs = append(errs, fieldError(envPath, "environment variable '%s' %s", name, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	if _, err := c.stagesInOrder(); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// stagesInOrder returns the stages in the order they are applied, stages
+// run in the order they are specified, dependencies are moved before the
+// stages depending on them
+func (c *Cluster) stagesInOrder() ([]*ClusterStage, error) {
+	var ordered []*ClusterStage
+	placed := map[string]bool{}
+	visiting := map[string]bool{}
+
+	var visit func(stage *ClusterStage, chain []string) error
+	visit = func(stage *ClusterStage, chain []string) error {
+		if placed[stage.Name] {
+			return nil
+		}
+		chain = append(chain, stage.Name)
+		if visiting[stage.Name] {
+			return fmt.Errorf("stages have circular dependencies: %s", strings.Join(chain, " -> "))
+		}
+		visiting[stage.Name] = true
+
+		for _, depend := range stage.Depends {
+			dependStage := c.stageByName(depend)
+			if dependStage == nil {
+				return fmt.Errorf("stage '%s' depends on unknown stage '%s'", stage.Name, depend)
+			}
+			if err := visit(dependStage, chain); err != nil {
+				return err
+			}
+		}
+
+		visiting[stage.Name] = false
+		placed[stage.Name] = true
+		ordered = append(ordered, stage)
+		return nil
+	}
+
+	for _, stage := range c.Stages {
+		if err := visit(stage, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return ordered, nil
+}
+
+// migrateStages converts the provider slots of clusters created before
+// stages existed, it returns true if the cluster has been migrated
+func (c *Cluster) migrateStages() bool {
+	if len(c.Stages) > 0 || len(c.ProviderImageNames) == 0 {
+		c.ProviderImageNames = nil
+		c.ProviderImageDigests = nil
+		return false
+	}
+
+	for _, providerName := range []string{"infrastructure", "config"} {
+		imageName := c.ProviderImageNames[providerName]
+		if imageName == nil || len(*imageName) == 0 {
+			continue
+		}
+		c.Stages = append(c.Stages, &ClusterStage{
+			Name:           providerName,
+			Image:          *imageName,
+			Digest:         c.ProviderImageDigests[providerName],
+			LegacyContract: c.legacyProviders(),
+		})
+	}
+
+	c.log().Debugf("migrated providers of cluster config version %s to stages", c.Version)
+	c.ProviderImageNames = nil
+	c.ProviderImageDigests = nil
+	c.Version = ClusterVersion
+
+	return true
+}
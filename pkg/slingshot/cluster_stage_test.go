@@ -0,0 +1,102 @@
+package slingshot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func stageNames(stages []*ClusterStage) (names []string) {
+	for _, stage := range stages {
+		names = append(names, stage.Name)
+	}
+	return
+}
+
+func TestParseStage(t *testing.T) {
+	stage, err := ParseStage("addons=example/addons:1.0")
+	assert.Nil(t, err, "Unexpected error during parsing")
+	assert.Equal(t, "addons", stage.Name)
+	assert.Equal(t, "example/addons:1.0", stage.Image)
+	assert.Equal(t, "addons", stage.ProviderType())
+
+	stage, err = ParseStage("local=dir:///tmp/provider")
+	assert.Nil(t, err, "Unexpected error during parsing")
+	assert.Equal(t, "dir:///tmp/provider", stage.Image)
+
+	_, err = ParseStage("example/addons")
+	assert.NotNil(t, err)
+	_, err = ParseStage("Addons=example/addons")
+	assert.NotNil(t, err)
+	_, err = ParseStage("addons=")
+	assert.NotNil(t, err)
+
+	name, depends, err := ParseStageDepends("addons=config, infrastructure")
+	assert.Nil(t, err, "Unexpected error during parsing")
+	assert.Equal(t, "addons", name)
+	assert.Equal(t, []string{"config", "infrastructure"}, depends)
+}
+
+func TestClusterStagesInOrder(t *testing.T) {
+	c := NewCluster(&Slingshot{})
+	for _, name := range []string{"infrastructure", "config", "addons", "preflight"} {
+		assert.Nil(t, c.AddStage(&ClusterStage{Name: name, Image: name}))
+	}
+	assert.NotNil(t, c.AddStage(&ClusterStage{Name: "config", Image: "config"}))
+
+	stages, err := c.stagesInOrder()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"infrastructure", "config", "addons", "preflight"}, stageNames(stages))
+
+	c.stageByName("infrastructure").Depends = []string{"preflight"}
+	c.stageByName("addons").Depends = []string{"config"}
+	assert.Empty(t, c.validateStages())
+	stages, err = c.stagesInOrder()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"preflight", "infrastructure", "config", "addons"}, stageNames(stages))
+
+	c.stageByName("preflight").Depends = []string{"config"}
+	c.stageByName("config").Depends = []string{"infrastructure"}
+	errs := c.validateStages()
+	if assert.Equal(t, 1, len(errs)) {
+		assert.Contains(t, errs[0].Error(), "circular dependencies: infrastructure -> preflight -> config -> infrastructure")
+	}
+
+	c.stageByName("preflight").Depends = []string{"monitoring"}
+	errs = c.validateStages()
+	if assert.Equal(t, 1, len(errs)) {
+		assert.Contains(t, errs[0].Error(), "unknown stage 'monitoring'")
+	}
+}
+
+func TestClusterMigrateStages(t *testing.T) {
+	c := NewCluster(&Slingshot{})
+	c.Version = ClusterVersionLegacy
+	err := yaml.Unmarshal([]byte(`name: test
+providerImageNames:
+  infrastructure: example/ip:1.0
+  config: example/cp:1.0
+providerImageDigests:
+  infrastructure: sha256:abcdef`), c)
+	assert.Nil(t, err, "Unexpected error during parsing")
+	assert.Nil(t, c.checkVersion())
+
+	assert.True(t, c.migrateStages())
+	assert.Equal(t, ClusterVersion, c.Version)
+	assert.Nil(t, c.ProviderImageNames)
+	assert.Nil(t, c.ProviderImageDigests)
+	assert.Equal(t, []string{"infrastructure", "config"}, stageNames(c.Stages))
+	assert.Equal(t, "example/ip:1.0", c.Stages[0].Image)
+	assert.Equal(t, "sha256:abcdef", c.Stages[0].Digest)
+	assert.True(t, c.Stages[0].LegacyContract)
+	assert.Equal(t, "", c.Stages[1].Digest)
+
+	// migrated clusters are written with stages only
+	yamlContents, err := yaml.Marshal(c)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(yamlContents), "providerImageNames")
+	assert.Contains(t, string(yamlContents), "stages:")
+
+	assert.False(t, c.migrateStages())
+}
@@ -0,0 +1,136 @@
+package slingshot
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v2"
+)
+
+const StatusUnknown = "unknown"
+
+type ClusterStatus struct {
+	Name              string            `yaml:"name"`
+	Providers         map[string]string `yaml:"providers"`
+	Hosts             []HostStatus      `yaml:"hosts"`
+	ApiEndpoint       string            `yaml:"apiEndpoint,omitempty"`
+	KubernetesVersion string            `yaml:"kubernetesVersion,omitempty"`
+	providerOrder     []string
+}
+
+type HostStatus struct {
+	Name  string   `yaml:"name"`
+	Roles []string `yaml:"roles,omitempty"`
+	State string   `yaml:"state"`
+}
+
+// ProviderStatus is the result file content of a provider's status command
+type ProviderStatus struct {
+	Hosts []struct {
+		Name  string `yaml:"name"`
+		State string `yaml:"state"`
+	} `yaml:"hosts"`
+	ApiEndpoint       string `yaml:"apiEndpoint"`
+	KubernetesVersion string `yaml:"kubernetesVersion"`
+}
+
+func (pS *ProviderStatus) Parse(content string) error {
+	err := yaml.Unmarshal([]byte(content), pS)
+	return err
+}
+
+func NewClusterStatus(name string, inventory []ParameterInventory) *ClusterStatus {
+	s := &ClusterStatus{
+		Name:      name,
+		Providers: map[string]string{},
+	}
+
+	for _, host := range inventory {
+		if host.Name == nil {
+			continue
+		}
+		s.Hosts = append(s.Hosts, HostStatus{
+			Name:  *host.Name,
+			Roles: host.Roles,
+			State: StatusUnknown,
+		})
+	}
+
+	return s
+}
+
+// SetProvider records the state of the provider of a stage, providers are
+// listed in the order they have been set
+func (s *ClusterStatus) SetProvider(name string, state string) {
+	if _, ok := s.Providers[name]; !ok {
+		s.providerOrder = append(s.providerOrder, name)
+	}
+	s.Providers[name] = state
+}
+
+// Merge applies the status reported by a provider
+func (s *ClusterStatus) Merge(pS *ProviderStatus) {
+	for _, reported := range pS.Hosts {
+		found := false
+		for pos := range s.Hosts {
+			if s.Hosts[pos].Name == reported.Name {
+				s.Hosts[pos].State = reported.State
+				found = true
+				break
+			}
+		}
+		if !found {
+			s.Hosts = append(s.Hosts, HostStatus{
+				Name:  reported.Name,
+				State: reported.State,
+			})
+		}
+	}
+
+	if len(pS.ApiEndpoint) > 0 {
+		s.ApiEndpoint = pS.ApiEndpoint
+	}
+	if len(pS.KubernetesVersion) > 0 {
+		s.KubernetesVersion = pS.KubernetesVersion
+	}
+}
+
+func (s *ClusterStatus) WriteYAML(out io.Writer) error {
+	yamlContents, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(yamlContents)
+	return err
+}
+
+func (s *ClusterStatus) WriteTable(out io.Writer) error {
+	w := new(tabwriter.Writer)
+
+	// Format in tab-separated columns with a tab stop of 8.
+	w.Init(out, 0, 8, 0, '\t', 0)
+
+	valueOrUnknown := func(value string) string {
+		if len(value) == 0 {
+			return StatusUnknown
+		}
+		return value
+	}
+
+	fmt.Fprintf(w, "Cluster Name:\t%s\n", s.Name)
+	fmt.Fprintf(w, "API Endpoint:\t%s\n", valueOrUnknown(s.ApiEndpoint))
+	fmt.Fprintf(w, "Kubernetes Version:\t%s\n", valueOrUnknown(s.KubernetesVersion))
+	for _, providerName := range s.providerOrder {
+		fmt.Fprintf(w, "Provider %s:\t%s\n", providerName, valueOrUnknown(s.Providers[providerName]))
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "Host Name\tRoles\tState")
+	for _, host := range s.Hosts {
+		fmt.Fprintf(w, "%s\t%v\t%s\n", host.Name, host.Roles, host.State)
+	}
+	fmt.Fprintln(w)
+
+	return w.Flush()
+}
@@ -0,0 +1,46 @@
+package slingshot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterStatusMerge(t *testing.T) {
+	p := &Parameters{}
+	err := p.Parse(`inventory:
+  - name: k8s-masters-1
+    roles:
+      - masters
+    privateIP: 192.168.51.51
+  - name: k8s-workers-1
+    roles:
+      - workers
+    privateIP: 192.168.51.52`)
+	assert.Nil(t, err, "Unexpected error during parsing")
+
+	s := NewClusterStatus("test", p.Inventory)
+	assert.Equal(t, 2, len(s.Hosts))
+	assert.Equal(t, StatusUnknown, s.Hosts[0].State)
+
+	pS := &ProviderStatus{}
+	err = pS.Parse(`hosts:
+  - name: k8s-masters-1
+    state: running
+  - name: k8s-workers-2
+    state: stopped
+apiEndpoint: https://192.168.51.51:443
+kubernetesVersion: v1.2.2`)
+	assert.Nil(t, err, "Unexpected error during parsing")
+
+	s.Merge(pS)
+
+	assert.Equal(t, 3, len(s.Hosts))
+	assert.Equal(t, "running", s.Hosts[0].State)
+	assert.Equal(t, []string{"masters"}, s.Hosts[0].Roles)
+	assert.Equal(t, StatusUnknown, s.Hosts[1].State)
+	assert.Equal(t, "k8s-workers-2", s.Hosts[2].Name)
+	assert.Equal(t, "stopped", s.Hosts[2].State)
+	assert.Equal(t, "https://192.168.51.51:443", s.ApiEndpoint)
+	assert.Equal(t, "v1.2.2", s.KubernetesVersion)
+}
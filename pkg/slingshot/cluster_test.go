@@ -0,0 +1,106 @@
+package slingshot
+
+import (
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/codegangsta/cli"
+	"github.com/simonswine/slingshot/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func destroyContext(force bool) *cli.Context {
+	set := flag.NewFlagSet("destroy", flag.ContinueOnError)
+	set.Bool("force", force, "")
+	return cli.NewContext(cli.NewApp(), set, nil)
+}
+
+func TestClusterDestroyWithoutDestroyCommand(t *testing.T) {
+	configDir, err := ioutil.TempDir("", AppName)
+	assert.Nil(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(configDir)
+
+	sourceDir := path.Join(configDir, "provider")
+	assert.Nil(t, utils.EnsureDirectory(sourceDir))
+	err = ioutil.WriteFile(path.Join(sourceDir, ProviderDirDiscoverFile), []byte(conformanceDiscover), 0644)
+	assert.Nil(t, err, "Unexpected error writing file")
+
+	c := NewCluster(&Slingshot{configDir: configDir})
+	c.Name = "test"
+	c.Parameters = &Parameters{}
+	c.Stages = []*ClusterStage{{Name: "infrastructure", Image: ProviderDirScheme + sourceDir}}
+	assert.Nil(t, c.WriteConfig())
+
+	// the provider can not destroy its resources, state is kept
+	errs := c.Destroy(destroyContext(false))
+	assert.Equal(t, 2, len(errs))
+	assert.Contains(t, errs[0].Error(), "does not declare a 'destroy' command")
+	_, err = os.Stat(c.configDirPath())
+	assert.Nil(t, err, "Expected local state to be kept")
+
+	errs = c.Destroy(destroyContext(true))
+	assert.Empty(t, errs)
+	_, err = os.Stat(c.configDirPath())
+	assert.True(t, os.IsNotExist(err), "Expected local state to be removed")
+}
+
+func TestClusterUpgradeProvidersSkipsDirectories(t *testing.T) {
+	configDir, err := ioutil.TempDir("", AppName)
+	assert.Nil(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(configDir)
+
+	c := NewCluster(&Slingshot{configDir: configDir})
+	c.Name = "test"
+	c.Parameters = &Parameters{}
+	c.Stages = []*ClusterStage{{Name: "infrastructure", Image: ProviderDirScheme + path.Join(configDir, "provider")}}
+
+	upgrades, errs := c.UpgradeProviders(nil)
+	assert.Empty(t, errs)
+	assert.Empty(t, upgrades)
+}
+
+func TestClusterCreateStagesAbsoluteProviderDir(t *testing.T) {
+	providers := &cli.StringSlice{"infrastructure=" + ProviderDirScheme + "providers/ip"}
+	set := flag.NewFlagSet("create", flag.ContinueOnError)
+	set.String("infrastructure-provider", "", "")
+	set.String("config-provider", ProviderDirScheme+"/srv/cp", "")
+	set.Var(providers, "provider", "")
+	set.Var(&cli.StringSlice{}, "depends", "")
+	set.Var(&cli.StringSlice{}, "env", "")
+
+	c := NewCluster(&Slingshot{})
+	errs := c.createStages(cli.NewContext(cli.NewApp(), set, nil))
+	assert.Empty(t, errs)
+
+	workDir, err := os.Getwd()
+	assert.Nil(t, err)
+	assert.Equal(t, ProviderDirScheme+"/srv/cp", c.stageByName("config").Image)
+	assert.Equal(t, ProviderDirScheme+path.Join(workDir, "providers/ip"), c.stageByName("infrastructure").Image)
+}
+
+func TestClusterDestroyNotLoaded(t *testing.T) {
+	configDir, err := ioutil.TempDir("", AppName)
+	assert.Nil(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(configDir)
+
+	c := NewCluster(&Slingshot{configDir: configDir})
+	c.Name = "broken"
+	assert.Nil(t, utils.EnsureDirectory(c.configDirPath()))
+	assert.Nil(t, ioutil.WriteFile(c.configFilePath(), []byte("stages: [\n"), 0600))
+	c.loadErr = errors.New("yaml: line 2: did not find expected node content")
+
+	errs := c.Destroy(destroyContext(false))
+	assert.Equal(t, 2, len(errs))
+	assert.Contains(t, errs[0].Error(), "could not be loaded")
+	_, err = os.Stat(c.configDirPath())
+	assert.Nil(t, err, "Expected local state to be kept")
+
+	errs = c.Destroy(destroyContext(true))
+	assert.Empty(t, errs)
+	_, err = os.Stat(c.configDirPath())
+	assert.True(t, os.IsNotExist(err), "Expected local state to be removed")
+}
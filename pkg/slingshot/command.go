@@ -6,12 +6,14 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/simonswine/slingshot/pkg/utils"
 )
 
 type CommandInterface interface {
-	Exec(command []string, stdout io.Writer, stderr io.Writer, stdin io.Reader) (exitCode int, err error)
+	Exec(execSingle *ExecConfig, stdout io.Writer, stderr io.Writer, stdin io.Reader) (exitCode int, err error)
 	Prepare(*[]byte) error
 	Output() ([]byte, error)
 	CleanUp()
@@ -22,17 +24,23 @@ type CommandInterface interface {
 }
 
 type CommandConfig struct {
-	ParameterFile     *string    `yaml:"parameterFile"`
-	ResultFile        *string    `yaml:"resultFile"`
-	PersistPaths      []string   `yaml:"persistPaths"`
-	Type              string     `yaml:"type"`
-	WorkingDirContent string     `yaml:"workingDirContent"`
-	Execs             [][]string `yaml:"execs"`
+	ParameterFile     *string               `yaml:"parameterFile"`
+	ResultFile        *string               `yaml:"resultFile"`
+	PersistPaths      []string              `yaml:"persistPaths"`
+	Type              string                `yaml:"type"`
+	WorkingDirContent string                `yaml:"workingDirContent"`
+	Execs             []ExecConfig          `yaml:"execs"`
+	Environment       []EnvironmentVariable `yaml:"environment,omitempty"`
+	sourceDir         string
+
+	// environment contains the resolved values of Environment
+	environment map[string]string
 }
 
 type Command struct {
 	commandImplementation CommandInterface
 	provider              ProviderInterface
+	readOnlyState         bool
 }
 
 func NewCommand(c *CommandConfig, p *Provider) (*Command, error) {
@@ -80,7 +88,7 @@ func (c *Command) Execute(command []string) (stdOut string, stdErr string, exitC
 	var bufOut bytes.Buffer
 	var bufErr bytes.Buffer
 
-	exitCode, err = c.commandImplementation.Exec(command, &bufOut, &bufErr, nil)
+	exitCode, err = c.commandImplementation.Exec(&ExecConfig{Argv: command}, &bufOut, &bufErr, nil)
 	if err != nil {
 		return
 	}
@@ -99,7 +107,22 @@ func (c *Command) Run(parameters *[]byte) (output []byte, err error) {
 	defer c.CleanUp()
 
 	for _, execSingle := range c.commandImplementation.Config().Execs {
-		_, err = c.commandImplementation.Exec(execSingle, os.Stdout, os.Stderr, nil)
+		if isInterrupted(c.provider.Interrupted()) {
+			err = ErrInterrupted
+			return
+		}
+
+		matches, errWhen := execSingle.Matches(parameters)
+		if errWhen != nil {
+			err = fmt.Errorf("Error while evaluating condition of exec '%s': %s", execSingle.String(), errWhen)
+			return
+		}
+		if !matches {
+			c.log().Infof("skipping exec '%s', condition '%s' is not met", execSingle.String(), execSingle.When)
+			continue
+		}
+
+		err = c.runExec(execSingle)
 		if err != nil {
 			return
 		}
@@ -108,6 +131,75 @@ func (c *Command) Run(parameters *[]byte) (output []byte, err error) {
 	return c.commandImplementation.Output()
 }
 
+// execAfter starts the wait between retries of an exec
+var execAfter = time.After
+
+// runExec runs a single exec, retries it on a non-zero exit code and fails
+// with an ExecError unless failure is allowed
+func (c *Command) runExec(execSingle ExecConfig) error {
+	delay, err := execSingle.retryDelay()
+	if err != nil {
+		return err
+	}
+
+	attempts := execSingle.Retries + 1
+	for attempt := 1; ; attempt++ {
+		tail := &stderrTail{max: ExecErrorStderrLines}
+		stderrLines := utils.NewLineWriter(tail.add)
+
+		c.log().Debugf("running exec '%s'", execSingle.String())
+		exitCode, err := c.commandImplementation.Exec(
+			&execSingle,
+			os.Stdout,
+			io.MultiWriter(os.Stderr, stderrLines),
+			nil,
+		)
+		stderrLines.Flush()
+
+		if err == ErrInterrupted {
+			c.log().Warnf("exec '%s' has been interrupted", execSingle.String())
+			return err
+		}
+
+		timeoutErr, timedOut := err.(*ExecTimeoutError)
+		if err != nil && !timedOut {
+			return err
+		}
+		if exitCode == 0 && !timedOut {
+			return nil
+		}
+
+		execErr := &ExecError{
+			Name:     execSingle.Name,
+			Argv:     execSingle.Argv,
+			ExitCode: exitCode,
+			Attempts: attempt,
+			TimedOut: timeoutErr,
+			Stderr:   tail.lines,
+		}
+
+		if attempt >= attempts {
+			if execSingle.AllowFailure {
+				c.log().Warnf("%s, failure is allowed", execErr)
+				return nil
+			}
+			return execErr
+		}
+
+		c.log().Warnf("exec '%s' failed, retry %d/%d in %s", execSingle.String(), attempt, execSingle.Retries, delay)
+		select {
+		case <-execAfter(delay):
+		case <-c.provider.Interrupted():
+		}
+		delay *= 2
+
+		if isInterrupted(c.provider.Interrupted()) {
+			c.log().Warnf("retry of exec '%s' has been interrupted", execSingle.String())
+			return ErrInterrupted
+		}
+	}
+}
+
 func (c *Command) Prepare(parameters *[]byte) error {
 	if err := c.commandImplementation.Prepare(parameters); err != nil {
 		return err
@@ -127,7 +219,7 @@ func (c *Command) Prepare(parameters *[]byte) error {
 func (c *Command) CleanUp() {
 	// persist state if needed
 	conf := c.commandImplementation.Config()
-	if conf != nil && len(conf.PersistPaths) > 0 {
+	if conf != nil && len(conf.PersistPaths) > 0 && !c.readOnlyState {
 		if err := c.persistState(conf.PersistPaths); err != nil {
 			c.log().Warn("persisting of state failed: ", err)
 		}
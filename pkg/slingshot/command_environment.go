@@ -0,0 +1,256 @@
+package slingshot
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/simonswine/slingshot/pkg/utils"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+var environmentNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// EnvironmentVariable is an environment variable a provider command
+// requires, its value is supplied by the user of the cluster
+type EnvironmentVariable struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+
+	// Secret values are only passed to the command, they are never logged
+	// and kept out of the config of provider containers
+	Secret bool `yaml:"secret,omitempty"`
+
+	// Optional variables are not passed if no value is supplied
+	Optional bool `yaml:"optional,omitempty"`
+}
+
+// EnvironmentSource tells where the value of an environment variable is
+// read from, the cluster config only stores the source and never the value
+type EnvironmentSource struct {
+	FromEnv  string `yaml:"fromEnv,omitempty"`
+	FromFile string `yaml:"fromFile,omitempty"`
+	Prompt   bool   `yaml:"prompt,omitempty"`
+}
+
+// ParseEnvironmentSource parses a source given as
+// stage.NAME=env:VAR, stage.NAME=file:PATH or stage.NAME=prompt
+func ParseEnvironmentSource(value string) (stageName string, name string, source *EnvironmentSource, err error) {
+	pos := strings.Index(value, "=")
+	dot := strings.Index(value, ".")
+	if pos < 0 || dot < 0 || dot > pos {
+		return "", "", nil, fmt.Errorf("environment '%s' is not in the format stage.NAME=env:VAR, stage.NAME=file:PATH or stage.NAME=prompt", value)
+	}
+	stageName = value[:dot]
+	name = value[dot+1 : pos]
+	sourceValue := value[pos+1:]
+
+	source = &EnvironmentSource{}
+	switch {
+	case strings.HasPrefix(sourceValue, "env:"):
+		source.FromEnv = strings.TrimPrefix(sourceValue, "env:")
+	case strings.HasPrefix(sourceValue, "file:"):
+		source.FromFile = strings.TrimPrefix(sourceValue, "file:")
+	case sourceValue == "prompt":
+		source.Prompt = true
+	default:
+		return "", "", nil, fmt.Errorf("unknown source '%s' for environment variable '%s', supported are env:VAR, file:PATH and prompt", sourceValue, name)
+	}
+
+	if err := source.Validate(); err != nil {
+		return "", "", nil, fmt.Errorf("environment variable '%s' %s", name, err)
+	}
+	return stageName, name, source, nil
+}
+
+func (s *EnvironmentSource) Validate() error {
+	sources := 0
+	if len(s.FromEnv) > 0 {
+		if !environmentNameRegexp.MatchString(s.FromEnv) {
+			return fmt.Errorf("reads from invalid variable name '%s'", s.FromEnv)
+		}
+		sources++
+	}
+	if len(s.FromFile) > 0 {
+		sources++
+	}
+	if s.Prompt {
+		sources++
+	}
+	if sources != 1 {
+		return fmt.Errorf("needs exactly one of fromEnv, fromFile or prompt")
+	}
+	return nil
+}
+
+func (s *EnvironmentSource) String() string {
+	switch {
+	case len(s.FromEnv) > 0:
+		return fmt.Sprintf("host environment variable '%s'", s.FromEnv)
+	case len(s.FromFile) > 0:
+		return fmt.Sprintf("file '%s'", s.FromFile)
+	}
+	return "prompt"
+}
+
+// environmentPrompt asks the user for the value of an environment variable
+var environmentPrompt = promptEnvironmentVariable
+
+func promptEnvironmentVariable(variable *EnvironmentVariable) (string, error) {
+	question := variable.Name
+	if len(variable.Description) > 0 {
+		question = fmt.Sprintf("%s (%s)", variable.Name, variable.Description)
+	}
+	fmt.Fprintf(os.Stderr, "%s: ", question)
+
+	// do not echo secrets typed into a terminal
+	fd := int(os.Stdin.Fd())
+	if variable.Secret && terminal.IsTerminal(fd) {
+		value, err := terminal.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(value), err
+	}
+
+	value, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.TrimRight(value, "\r\n"), err
+}
+
+// resolve reads the value of variable from the source, found is false if
+// the source has no value
+func (s *EnvironmentSource) resolve(variable *EnvironmentVariable) (value string, found bool, err error) {
+	switch {
+	case len(s.FromEnv) > 0:
+		value, found = os.LookupEnv(s.FromEnv)
+		return value, found, nil
+
+	case len(s.FromFile) > 0:
+		filePath := s.FromFile
+		if strings.HasPrefix(filePath, "~/") {
+			homeDir, err := utils.UserHomeDir()
+			if err != nil {
+				return "", false, err
+			}
+			filePath = path.Join(homeDir, filePath[2:])
+		}
+		content, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return "", false, fmt.Errorf("Error while reading environment variable '%s': %s", variable.Name, err)
+		}
+		return strings.TrimRight(string(content), "\r\n"), true, nil
+
+	case s.Prompt:
+		value, err = environmentPrompt(variable)
+		if err != nil {
+			return "", false, fmt.Errorf("Error while reading environment variable '%s': %s", variable.Name, err)
+		}
+		return value, true, nil
+	}
+
+	return "", false, nil
+}
+
+// validateEnvironment checks the environment variables declared by a
+// command
+func validateEnvironment(fieldPath string, variables []EnvironmentVariable) (errs []error) {
+	seen := map[string]bool{}
+	for pos, variable := range variables {
+		if !environmentNameRegexp.MatchString(variable.Name) {
+			errs = append(errs, fieldError(fmt.Sprintf("%s[%d].name", fieldPath, pos), "environment variable name '%s' did not match '%s'", variable.Name, environmentNameRegexp))
+		}
+		if seen[variable.Name] {
+			errs = append(errs, fieldError(fmt.Sprintf("%s[%d].name", fieldPath, pos), "environment variable '%s' is declared more than once", variable.Name))
+		}
+		seen[variable.Name] = true
+	}
+	return errs
+}
+
+// environment resolves the values of the environment variables declared by
+// a command, values without a configured source are read from the host
+// environment variable of the same name
+func (p *Provider) environment(commandName string, commandDef *CommandConfig) (map[string]string, error) {
+	env := map[string]string{}
+
+	for pos := range commandDef.Environment {
+		variable := &commandDef.Environment[pos]
+
+		if value, ok := p.environmentValues[variable.Name]; ok {
+			env[variable.Name] = value
+			continue
+		}
+
+		source, configured := p.environmentSources[variable.Name]
+		if !configured {
+			source = &EnvironmentSource{FromEnv: variable.Name}
+		}
+
+		value, found, err := source.resolve(variable)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			if variable.Optional {
+				continue
+			}
+			return nil, fmt.Errorf(
+				"environment variable '%s' required by command '%s' is not set, please set it or use '--env %s.%s=env:VAR', '--env %s.%s=file:PATH' or '--env %s.%s=prompt'",
+				variable.Name,
+				commandName,
+				p.providerType, variable.Name,
+				p.providerType, variable.Name,
+				p.providerType, variable.Name,
+			)
+		}
+
+		p.Log().Debugf("read environment variable '%s' from %s", variable.Name, source)
+		if p.environmentValues == nil {
+			p.environmentValues = map[string]string{}
+		}
+		p.environmentValues[variable.Name] = value
+		env[variable.Name] = value
+	}
+
+	return env, nil
+}
+
+// splitEnvironment splits the resolved environment of the command into the
+// values of secret variables and all other values
+func (c *CommandConfig) splitEnvironment() (public map[string]string, secret map[string]string) {
+	public = map[string]string{}
+	secret = map[string]string{}
+	for name, value := range c.environment {
+		public[name] = value
+	}
+	for _, variable := range c.Environment {
+		if value, ok := public[variable.Name]; ok && variable.Secret {
+			secret[variable.Name] = value
+			delete(public, variable.Name)
+		}
+	}
+	return public, secret
+}
+
+// environmentScript returns a shell script exporting env
+func environmentScript(env map[string]string) []byte {
+	var script bytes.Buffer
+	for _, entry := range environmentList(env) {
+		pos := strings.Index(entry, "=")
+		fmt.Fprintf(&script, "export %s='%s'\n", entry[:pos], strings.Replace(entry[pos+1:], "'", `'\''`, -1))
+	}
+	return script.Bytes()
+}
+
+// environmentList returns env as sorted KEY=value list
+func environmentList(env map[string]string) (list []string) {
+	for key, value := range env {
+		list = append(list, fmt.Sprintf("%s=%s", key, value))
+	}
+	sort.Strings(list)
+	return list
+}
@@ -0,0 +1,191 @@
+package slingshot
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+const environmentDiscover = `provider:
+  type: infrastructure
+  version: "1"
+commands:
+  apply:
+    type: host
+    parameterFile: parameters.yaml
+    resultFile: result.yaml
+    environment:
+      - name: CLOUD_USER
+      - name: CLOUD_TOKEN
+        secret: true
+      - name: CLOUD_REGION
+        optional: true
+    execs:
+      - [/bin/sh, -c, 'echo "$CLOUD_USER:$CLOUD_TOKEN:${CLOUD_REGION:-none}" > result.yaml']
+`
+
+func prepareEnvironmentProvider(t *testing.T) (*Provider, func()) {
+	sourceDir, err := ioutil.TempDir("", AppName)
+	assert.Nil(t, err, "Unexpected error creating temp dir")
+
+	err = ioutil.WriteFile(path.Join(sourceDir, ProviderDirDiscoverFile), []byte(environmentDiscover), 0644)
+	assert.Nil(t, err, "Unexpected error writing file")
+
+	p, err := NewStandaloneProvider(&Slingshot{}, ProviderDirScheme+sourceDir)
+	assert.Nil(t, err, "Unexpected error initialising provider")
+
+	return p, func() {
+		os.RemoveAll(sourceDir)
+	}
+}
+
+func TestParseEnvironmentSource(t *testing.T) {
+	stageName, name, source, err := ParseEnvironmentSource("infrastructure.AWS_SECRET_ACCESS_KEY=env:MY_AWS_SECRET")
+	assert.Nil(t, err)
+	assert.Equal(t, "infrastructure", stageName)
+	assert.Equal(t, "AWS_SECRET_ACCESS_KEY", name)
+	assert.Equal(t, &EnvironmentSource{FromEnv: "MY_AWS_SECRET"}, source)
+
+	_, _, source, err = ParseEnvironmentSource("config.TOKEN=file:/etc/token=1")
+	assert.Nil(t, err)
+	assert.Equal(t, &EnvironmentSource{FromFile: "/etc/token=1"}, source)
+
+	_, _, source, err = ParseEnvironmentSource("config.TOKEN=prompt")
+	assert.Nil(t, err)
+	assert.Equal(t, &EnvironmentSource{Prompt: true}, source)
+
+	for _, invalid := range []string{
+		"TOKEN=prompt",
+		"config.TOKEN",
+		"config.TOKEN=secret",
+		"config.TOKEN=env:not-valid",
+		"config.TOKEN=file:",
+	} {
+		_, _, _, err = ParseEnvironmentSource(invalid)
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestProviderEnvironment(t *testing.T) {
+	p, cleanUp := prepareEnvironmentProvider(t)
+	defer cleanUp()
+
+	tokenFile, err := ioutil.TempFile("", AppName)
+	assert.Nil(t, err, "Unexpected error creating temp file")
+	defer os.Remove(tokenFile.Name())
+	tokenFile.WriteString("s3cr3t\n")
+	tokenFile.Close()
+
+	os.Setenv("SLINGSHOT_TEST_CLOUD_USER", "admin")
+	defer os.Unsetenv("SLINGSHOT_TEST_CLOUD_USER")
+	os.Unsetenv("CLOUD_USER")
+	os.Unsetenv("CLOUD_REGION")
+
+	// required variable without value
+	_, err = p.RunCommand("apply", nil)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "environment variable 'CLOUD_USER' required by command 'apply' is not set")
+	}
+
+	p.environmentSources = map[string]*EnvironmentSource{
+		"CLOUD_USER":  {FromEnv: "SLINGSHOT_TEST_CLOUD_USER"},
+		"CLOUD_TOKEN": {FromFile: tokenFile.Name()},
+	}
+	output, err := p.RunCommand("apply", nil)
+	assert.Nil(t, err, "Unexpected error running apply")
+	assert.Equal(t, "admin:s3cr3t:none\n", string(output))
+
+	// prompted values are asked for once
+	prompts := 0
+	environmentPrompt = func(variable *EnvironmentVariable) (string, error) {
+		prompts++
+		return "eu-west-1", nil
+	}
+	defer func() {
+		environmentPrompt = promptEnvironmentVariable
+	}()
+	p.environmentSources["CLOUD_REGION"] = &EnvironmentSource{Prompt: true}
+	for i := 0; i < 2; i++ {
+		output, err = p.RunCommand("apply", nil)
+		assert.Nil(t, err, "Unexpected error running apply")
+		assert.Equal(t, "admin:s3cr3t:eu-west-1\n", string(output))
+	}
+	assert.Equal(t, 1, prompts)
+}
+
+func TestClusterStageEnvironmentConfig(t *testing.T) {
+	c := NewCluster(&Slingshot{})
+	c.Stages = []*ClusterStage{{
+		Name:  "infrastructure",
+		Image: "example/ip:1.0",
+		Environment: map[string]*EnvironmentSource{
+			"CLOUD_TOKEN": {FromFile: "~/.cloud/token"},
+		},
+	}}
+
+	// only the source is stored
+	content, err := yaml.Marshal(c)
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "fromFile: ~/.cloud/token")
+
+	assert.Empty(t, c.validateStages())
+
+	c.Stages[0].Environment["CLOUD_USER"] = &EnvironmentSource{FromEnv: "USER", Prompt: true}
+	c.Stages[0].Environment["not-valid"] = &EnvironmentSource{Prompt: true}
+	var paths []string
+	for _, valErr := range ValidationErrors(c.validateStages()) {
+		paths = append(paths, valErr.Path)
+	}
+	assert.Equal(t, []string{
+		"stages[0].environment.CLOUD_USER",
+		"stages[0].environment.not-valid",
+	}, paths)
+
+	config := &ProviderConfig{}
+	assert.Nil(t, config.Parse(environmentDiscover))
+	assert.Empty(t, config.ValidateSchema())
+	config.Commands["apply"] = CommandConfig{
+		Type:          "host",
+		ParameterFile: config.Commands["apply"].ParameterFile,
+		ResultFile:    config.Commands["apply"].ResultFile,
+		Execs:         config.Commands["apply"].Execs,
+		Environment:   []EnvironmentVariable{{Name: "A"}, {Name: "A"}, {Name: "1B"}},
+	}
+	paths = nil
+	for _, valErr := range ValidationErrors(config.ValidateSchema()) {
+		paths = append(paths, valErr.Path)
+	}
+	assert.Equal(t, []string{
+		"commands.apply.environment[1].name",
+		"commands.apply.environment[2].name",
+	}, paths)
+}
+
+func TestCommandConfigSplitEnvironment(t *testing.T) {
+	c := &CommandConfig{
+		Environment: []EnvironmentVariable{
+			{Name: "CLOUD_USER"},
+			{Name: "CLOUD_TOKEN", Secret: true},
+			{Name: "CLOUD_REGION", Secret: true, Optional: true},
+		},
+		environment: map[string]string{
+			"CLOUD_USER":  "admin",
+			"CLOUD_TOKEN": "it's s3cr3t",
+		},
+	}
+
+	public, secret := c.splitEnvironment()
+	assert.Equal(t, map[string]string{"CLOUD_USER": "admin"}, public)
+	assert.Equal(t, map[string]string{"CLOUD_TOKEN": "it's s3cr3t"}, secret)
+
+	// the script exports the values unchanged
+	script := string(environmentScript(secret)) + `printf '%s' "$CLOUD_TOKEN"`
+	output, err := exec.Command("/bin/sh", "-c", script).Output()
+	assert.Nil(t, err, "Unexpected error running script")
+	assert.Equal(t, "it's s3cr3t", string(output))
+}
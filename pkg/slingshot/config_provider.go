@@ -1,5 +0,0 @@
-package slingshot
-
-type ConfigProvider struct {
-	Provider
-}
@@ -3,14 +3,19 @@ package slingshot
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"path"
+	"time"
 
 	"github.com/fsouza/go-dockerclient"
 	"github.com/simonswine/slingshot/pkg/utils"
 )
 
-var DockerSleepCommand = []string{"/bin/sleep", "3600"}
+// DockerInitCommand keeps the provider container running until slingshot
+// closes its stdin or writes a line to it, if slingshot dies its attach
+// connection and so stdin are closed by docker
+var DockerInitCommand = []string{"/bin/sh", "-c", "read -r control"}
 var DockerDefaultEntrypoint = []string{"/bin/sh", "-c"}
 
 type DockerCommand struct {
@@ -18,6 +23,14 @@ type DockerCommand struct {
 	containerId *string
 	entrypoint  *[]string
 	workDir     *string
+
+	// control is the stdin of the init of the container
+	control io.WriteCloser
+	attach  docker.CloseWaiter
+
+	// secretEnvironment is kept out of the container config, it is uploaded
+	// for every exec instead
+	secretEnvironment map[string]string
 }
 
 func (c *DockerCommand) getImageConfig() {
@@ -35,20 +48,39 @@ func (c *DockerCommand) getImageConfig() {
 func (c *DockerCommand) Prepare(parameters *[]byte) error {
 	c.getImageConfig()
 
+	// the environment of the command is set on the container, so values
+	// do not show up in the arguments of execs, secret values would show up
+	// when inspecting the container
+	var env []string
+	if c.config != nil {
+		public, secret := c.config.splitEnvironment()
+		env = environmentList(public)
+		c.secretEnvironment = secret
+	}
+
 	container, err := c.provider.Docker().CreateContainer(docker.CreateContainerOptions{
 		Config: &docker.Config{
 			Image:      *c.provider.DockerImageId(),
-			Cmd:        DockerSleepCommand,
+			Cmd:        DockerInitCommand,
 			Entrypoint: []string{},
+			Env:        env,
+			OpenStdin:  true,
+			StdinOnce:  true,
 		},
 	})
-	c.containerId = &container.ID
 	if err != nil {
 		return err
 	}
+	c.containerId = &container.ID
+
+	if err := c.attachControl(); err != nil {
+		c.CleanUp()
+		return err
+	}
 
 	err = c.provider.Docker().StartContainer(*c.containerId, nil)
 	if err != nil {
+		c.CleanUp()
 		return err
 	}
 
@@ -64,6 +96,7 @@ func (c *DockerCommand) Prepare(parameters *[]byte) error {
 			0644,
 		)
 		if err != nil {
+			c.CleanUp()
 			return err
 		}
 		c.log().Debugf("wrote parameters file to '%s'", filePath)
@@ -72,6 +105,68 @@ func (c *DockerCommand) Prepare(parameters *[]byte) error {
 	return nil
 }
 
+// attachControl attaches to the stdin of the init of the container, the
+// container stops once it is closed
+func (c *DockerCommand) attachControl() error {
+	controlReader, controlWriter := io.Pipe()
+	attach, err := c.provider.Docker().AttachToContainerNonBlocking(docker.AttachToContainerOptions{
+		Container:   *c.containerId,
+		InputStream: controlReader,
+		Stdin:       true,
+		Stream:      true,
+	})
+	if err != nil {
+		controlWriter.Close()
+		return fmt.Errorf("Error while attaching to provider container: %s", err)
+	}
+
+	c.control = controlWriter
+	c.attach = attach
+	return nil
+}
+
+// ContainerExitedError is returned if the provider container stopped while
+// slingshot still needed it
+type ContainerExitedError struct {
+	ContainerId string
+	State       docker.State
+}
+
+func (e *ContainerExitedError) Error() string {
+	containerId := e.ContainerId
+	if len(containerId) > 12 {
+		containerId = containerId[:12]
+	}
+
+	msg := fmt.Sprintf(
+		"provider container %s exited unexpectedly with exitcode=%d",
+		containerId,
+		e.State.ExitCode,
+	)
+	if e.State.OOMKilled {
+		msg += ", it ran out of memory"
+	}
+	if len(e.State.Error) > 0 {
+		msg += fmt.Sprintf(": %s", e.State.Error)
+	}
+	return msg
+}
+
+// checkContainer fails if the container is no longer running
+func (c *DockerCommand) checkContainer() error {
+	container, err := c.provider.Docker().InspectContainer(*c.containerId)
+	if err != nil {
+		return fmt.Errorf("Error while inspecting provider container: %s", err)
+	}
+	if !container.State.Running {
+		return &ContainerExitedError{
+			ContainerId: *c.containerId,
+			State:       container.State,
+		}
+	}
+	return nil
+}
+
 func (c *DockerCommand) ReadTar(statePaths []string) (tarData []byte, err error) {
 
 	var tarArchives [][]byte
@@ -161,6 +256,16 @@ func (c *DockerCommand) downloadFile(path string) (content []byte, err error) {
 }
 
 func (c *DockerCommand) CleanUp() {
+	// stop the init of the container
+	if c.control != nil {
+		c.control.Close()
+		c.control = nil
+	}
+	if c.attach != nil {
+		c.attach.Close()
+		c.attach = nil
+	}
+
 	if c.containerId != nil {
 		err := c.provider.Docker().RemoveContainer(docker.RemoveContainerOptions{
 			ID:    *c.containerId,
@@ -173,15 +278,59 @@ func (c *DockerCommand) CleanUp() {
 	}
 }
 
-func (c *DockerCommand) Exec(execCommand []string, stdout io.Writer, stderr io.Writer, stdin io.Reader) (exitCode int, err error) {
+// dockerExecWrapper writes its PID to the file given as first argument,
+// changes into the directory given as second argument, sources and removes
+// the environment file given as third argument if it is not empty and runs
+// the remaining arguments with env, so environment variables can be passed
+// as KEY=value in front of the command
+var dockerExecWrapper = []string{"/bin/sh", "-c", `echo $$ > "$0" && cd "${1:-.}" && if [ -n "$2" ]; then . "$2" && rm -f "$2"; fi && shift 2 && exec env "$@"`}
+
+// dockerSignalCommand sends the signal given as first argument to the
+// process group of the exec whose PID is in the file given as second
+// argument, or to the process if it does not lead a group
+var dockerSignalCommand = []string{"/bin/sh", "-c", `pid=$(cat "$1") && { kill -$0 -$pid 2>/dev/null || kill -$0 $pid; }`}
+
+func (c *DockerCommand) Exec(execSingle *ExecConfig, stdout io.Writer, stderr io.Writer, stdin io.Reader) (exitCode int, err error) {
 
 	var execIncludingEntrypoint []string
 
 	if c.entrypoint != nil {
-		execIncludingEntrypoint = append(*c.entrypoint)
+		execIncludingEntrypoint = append(execIncludingEntrypoint, *c.entrypoint...)
+	}
+
+	execIncludingEntrypoint = append(execIncludingEntrypoint, execSingle.Argv...)
+
+	timeout, err := execSingle.timeout()
+	if err != nil {
+		return
+	}
+
+	// fail clearly instead of with an exec error if the container is gone
+	if err = c.checkContainer(); err != nil {
+		return
 	}
 
-	execIncludingEntrypoint = append(execIncludingEntrypoint, execCommand...)
+	// the exec API of docker takes neither environment nor working dir and
+	// can not kill an exec, the wrapper records the PID to stop it
+	var pidFile string
+	if len(execSingle.Env) > 0 || len(execSingle.WorkDir) > 0 || timeout > 0 || len(c.secretEnvironment) > 0 {
+		execPrefix := fmt.Sprintf("/tmp/.slingshot-exec-%d", time.Now().UnixNano())
+		pidFile = execPrefix + ".pid"
+
+		// secret values are only readable by the exec, which removes them
+		var envFile string
+		if len(c.secretEnvironment) > 0 {
+			envFile = execPrefix + ".env"
+			if err = c.uploadFile(envFile, environmentScript(c.secretEnvironment), 0600); err != nil {
+				return -1, fmt.Errorf("Error while uploading secret environment: %s", err)
+			}
+		}
+
+		wrapped := append([]string{}, dockerExecWrapper...)
+		wrapped = append(wrapped, pidFile, path.Join(*c.workDir, execSingle.WorkDir), envFile)
+		wrapped = append(wrapped, execSingle.EnvList()...)
+		execIncludingEntrypoint = append(wrapped, execIncludingEntrypoint...)
+	}
 
 	createOpts := docker.CreateExecOptions{
 		Cmd:       execIncludingEntrypoint,
@@ -208,10 +357,35 @@ func (c *DockerCommand) Exec(execCommand []string, stdout io.Writer, stderr io.W
 	}
 
 	c.log().WithField("command", execIncludingEntrypoint).Debugf("run command")
-	err = c.provider.Docker().StartExec(
-		execDocker.ID,
-		startOpts,
-	)
+	started := make(chan error, 1)
+	go func() {
+		started <- c.provider.Docker().StartExec(
+			execDocker.ID,
+			startOpts,
+		)
+	}()
+
+	var timer <-chan time.Time
+	if timeout > 0 {
+		timer = time.After(timeout)
+	}
+	select {
+	case err = <-started:
+	case <-timer:
+		// a timed out exec is only retried once it has stopped
+		if !c.stopExec(pidFile, started) {
+			return -1, fmt.Errorf("exec '%s' timed out after %s and could not be stopped", execSingle.String(), timeout)
+		}
+		return -1, &ExecTimeoutError{Timeout: timeout}
+	case <-c.interrupted():
+		c.interruptExecs()
+		select {
+		case <-started:
+		case <-time.After(ExecInterruptGracePeriod):
+			c.log().Warnf("exec did not stop within %s, it is killed with the container", ExecInterruptGracePeriod)
+		}
+		return -1, ErrInterrupted
+	}
 	if err != nil {
 		return
 	}
@@ -225,6 +399,56 @@ func (c *DockerCommand) Exec(execCommand []string, stdout io.Writer, stderr io.W
 	return
 }
 
+// dockerInterruptCommand interrupts all processes in the container apart
+// from its init
+var dockerInterruptCommand = []string{"/bin/sh", "-c", "kill -INT -1"}
+
+// interruptExecs sends an interrupt to the running execs, so they can stop
+// before the state is persisted
+func (c *DockerCommand) interruptExecs() {
+	if _, err := c.controlExec(dockerInterruptCommand); err != nil {
+		c.log().Warnf("interrupting execs failed: %s", err)
+	}
+}
+
+// stopExec terminates the exec that wrote pidFile and waits until it has
+// exited, it is killed if it does not stop within the grace period
+func (c *DockerCommand) stopExec(pidFile string, started <-chan error) bool {
+	for _, signal := range []string{"TERM", "KILL"} {
+		cmd := append(append([]string{}, dockerSignalCommand...), signal, pidFile)
+		if exitCode, err := c.controlExec(cmd); err != nil || exitCode != 0 {
+			c.log().Warnf("sending %s to exec failed: exitcode=%d %v", signal, exitCode, err)
+		}
+
+		select {
+		case <-started:
+			return true
+		case <-time.After(ExecInterruptGracePeriod):
+			c.log().Warnf("exec did not stop within %s after %s", ExecInterruptGracePeriod, signal)
+		}
+	}
+	return false
+}
+
+// controlExec runs cmd in the container without attaching to it
+func (c *DockerCommand) controlExec(cmd []string) (exitCode int, err error) {
+	execDocker, err := c.provider.Docker().CreateExec(docker.CreateExecOptions{
+		Cmd:       cmd,
+		Container: *c.containerId,
+	})
+	if err != nil {
+		return
+	}
+	if err = c.provider.Docker().StartExec(execDocker.ID, docker.StartExecOptions{}); err != nil {
+		return
+	}
+	execInspect, err := c.provider.Docker().InspectExec(execDocker.ID)
+	if err != nil {
+		return
+	}
+	return execInspect.ExitCode, nil
+}
+
 func (c *DockerCommand) Output() (output []byte, err error) {
 	if c.config != nil && c.config.ResultFile != nil {
 		filePath := path.Join(
@@ -1,8 +1,10 @@
 package slingshot
 
 import (
+	"bytes"
 	"testing"
 
+	"github.com/fsouza/go-dockerclient"
 	"github.com/stretchr/testify/assert"
 	"os"
 )
@@ -78,3 +80,84 @@ func TestDockerCommandPersistence(t *testing.T) {
 	assert.Equal(t, "test654\n", stdout)
 
 }
+
+func TestDockerCommandContainerExited(t *testing.T) {
+	c := prepareDockerCommand(t)
+
+	err := c.Prepare(nil)
+	assert.Nil(t, err, "Unexpected error during prepare")
+	defer c.CleanUp()
+
+	// a line on the control stdin stops the init of the container
+	dockerCommand := c.commandImplementation.(*DockerCommand)
+	_, err = dockerCommand.control.Write([]byte("stop\n"))
+	assert.Nil(t, err, "Unexpected error writing control")
+	_, err = dockerCommand.provider.Docker().WaitContainer(*dockerCommand.containerId)
+	assert.Nil(t, err, "Unexpected error waiting for container")
+
+	_, err = dockerCommand.Exec(&ExecConfig{Argv: []string{"true"}}, nil, nil, nil)
+	_, ok := err.(*ContainerExitedError)
+	assert.True(t, ok, "Expected a ContainerExitedError, got %v", err)
+}
+
+func TestContainerExitedError(t *testing.T) {
+	err := &ContainerExitedError{
+		ContainerId: "2e5b8c3a6e1f9d0c4b7a8e2f1d3c5b7a9e0f2d4c6b8a0e2f4d6c8b0a2e4f6d8c",
+		State: docker.State{
+			ExitCode:  137,
+			OOMKilled: true,
+		},
+	}
+	assert.Equal(t, "provider container 2e5b8c3a6e1f exited unexpectedly with exitcode=137, it ran out of memory", err.Error())
+}
+
+func TestDockerCommandExecTimeout(t *testing.T) {
+	c := prepareDockerCommand(t)
+
+	err := c.Prepare(nil)
+	assert.Nil(t, err, "Unexpected error during prepare")
+	defer c.CleanUp()
+
+	_, err = c.commandImplementation.Exec(&ExecConfig{Argv: []string{"sleep", "30"}, Timeout: "1s"}, nil, nil, nil)
+	_, ok := err.(*ExecTimeoutError)
+	assert.True(t, ok, "Expected an ExecTimeoutError, got %v", err)
+
+	// the timed out exec has been stopped
+	var stdout bytes.Buffer
+	_, err = c.commandImplementation.Exec(&ExecConfig{Argv: []string{"/bin/sh", "-c", "pgrep sleep || true"}}, &stdout, nil, nil)
+	assert.Nil(t, err, "Unexpected error during execution")
+	assert.Equal(t, "", stdout.String())
+}
+
+func TestDockerCommandSecretEnvironment(t *testing.T) {
+	c := prepareDockerCommand(t)
+	c.commandImplementation.Config().Environment = []EnvironmentVariable{
+		{Name: "CLOUD_USER"},
+		{Name: "CLOUD_TOKEN", Secret: true},
+	}
+	c.commandImplementation.Config().environment = map[string]string{
+		"CLOUD_USER":  "admin",
+		"CLOUD_TOKEN": "s3cr3t",
+	}
+
+	err := c.Prepare(nil)
+	assert.Nil(t, err, "Unexpected error during prepare")
+	defer c.CleanUp()
+
+	// secret values are not part of the container config
+	dockerCommand := c.commandImplementation.(*DockerCommand)
+	container, err := dockerCommand.provider.Docker().InspectContainer(*dockerCommand.containerId)
+	assert.Nil(t, err, "Unexpected error inspecting container")
+	assert.Contains(t, container.Config.Env, "CLOUD_USER=admin")
+	for _, env := range container.Config.Env {
+		assert.NotContains(t, env, "s3cr3t")
+	}
+
+	// every exec gets the secret values
+	for i := 0; i < 2; i++ {
+		var stdout bytes.Buffer
+		_, err = dockerCommand.Exec(&ExecConfig{Argv: []string{"/bin/sh", "-c", "echo $CLOUD_USER:$CLOUD_TOKEN"}}, &stdout, nil, nil)
+		assert.Nil(t, err, "Unexpected error during execution")
+		assert.Equal(t, "admin:s3cr3t\n", stdout.String())
+	}
+}
@@ -0,0 +1,273 @@
+package slingshot
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ExecErrorStderrLines is the number of stderr lines kept in an ExecError
+const ExecErrorStderrLines = 20
+
+// ExecRetryDelayDefault is the delay before the first retry of an exec, it
+// doubles with every further retry
+const ExecRetryDelayDefault = 5 * time.Second
+
+// ExecConfig is a single exec of a command, in the discover output it is
+// either a list of arguments or a map with the arguments in argv
+type ExecConfig struct {
+	Name         string            `yaml:"name,omitempty"`
+	Argv         []string          `yaml:"argv"`
+	Env          map[string]string `yaml:"env,omitempty"`
+	WorkDir      string            `yaml:"workDir,omitempty"`
+	Timeout      string            `yaml:"timeout,omitempty"`
+	AllowFailure bool              `yaml:"allowFailure,omitempty"`
+	Retries      int               `yaml:"retries,omitempty"`
+	RetryDelay   string            `yaml:"retryDelay,omitempty"`
+
+	// When is a parameter path, the exec is skipped if the parameter is
+	// not set, false, zero or empty. It can be negated with a leading '!'
+	// or compared with '==' and '!='
+	When string `yaml:"when,omitempty"`
+}
+
+func (e *ExecConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	// detect the form first, a failed unmarshal is reported by yaml even if
+	// the second form succeeds
+	var raw interface{}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+
+	if _, ok := raw.([]interface{}); ok {
+		var argv []string
+		if err := unmarshal(&argv); err != nil {
+			return err
+		}
+		*e = ExecConfig{Argv: argv}
+		return e.Validate()
+	}
+
+	type execConfigPlain ExecConfig
+	plain := execConfigPlain{}
+	if err := unmarshal(&plain); err != nil {
+		return err
+	}
+	*e = ExecConfig(plain)
+
+	return e.Validate()
+}
+
+func (e *ExecConfig) Validate() error {
+	if len(e.Argv) == 0 {
+		return fmt.Errorf("exec has no argv")
+	}
+	if e.Retries < 0 {
+		return fmt.Errorf("exec '%s' has negative retries", e)
+	}
+	if _, err := e.retryDelay(); err != nil {
+		return fmt.Errorf("exec '%s' has invalid retryDelay: %s", e, err)
+	}
+	if _, err := e.timeout(); err != nil {
+		return fmt.Errorf("exec '%s' has invalid timeout: %s", e, err)
+	}
+	if cleaned := path.Clean(e.WorkDir); path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return fmt.Errorf("exec '%s' has workDir '%s' outside of the working dir", e, e.WorkDir)
+	}
+	if _, err := parseExecCondition(e.When); err != nil {
+		return fmt.Errorf("exec '%s' has invalid condition: %s", e, err)
+	}
+	return nil
+}
+
+// String returns the name of the exec or its arguments
+func (e *ExecConfig) String() string {
+	if len(e.Name) > 0 {
+		return e.Name
+	}
+	return strings.Join(e.Argv, " ")
+}
+
+// EnvList returns the environment of the exec as sorted KEY=value list
+func (e *ExecConfig) EnvList() []string {
+	return environmentList(e.Env)
+}
+
+func (e *ExecConfig) timeout() (time.Duration, error) {
+	if len(e.Timeout) == 0 {
+		return 0, nil
+	}
+	return time.ParseDuration(e.Timeout)
+}
+
+// Matches evaluates the when condition against the YAML parameters
+func (e *ExecConfig) Matches(parameters *[]byte) (bool, error) {
+	condition, err := parseExecCondition(e.When)
+	if err != nil || condition == nil {
+		return true, err
+	}
+
+	values := map[interface{}]interface{}{}
+	if parameters != nil {
+		if err := yaml.Unmarshal(*parameters, &values); err != nil {
+			return false, err
+		}
+	}
+	return condition.matches(values), nil
+}
+
+// execCondition is a parsed when condition of an exec
+type execCondition struct {
+	path     []string
+	negate   bool
+	operator string
+	value    string
+}
+
+func parseExecCondition(when string) (*execCondition, error) {
+	when = strings.TrimSpace(when)
+	if len(when) == 0 {
+		return nil, nil
+	}
+
+	c := &execCondition{}
+	for _, operator := range []string{"==", "!="} {
+		if pos := strings.Index(when, operator); pos >= 0 {
+			c.operator = operator
+			c.value = strings.Trim(strings.TrimSpace(when[pos+len(operator):]), `"'`)
+			when = strings.TrimSpace(when[:pos])
+			break
+		}
+	}
+
+	if len(c.operator) == 0 && strings.HasPrefix(when, "!") {
+		c.negate = true
+		when = strings.TrimSpace(when[1:])
+	}
+
+	if len(when) == 0 {
+		return nil, fmt.Errorf("no parameter path given")
+	}
+	c.path = strings.Split(when, ".")
+	for _, elem := range c.path {
+		if len(elem) == 0 {
+			return nil, fmt.Errorf("parameter path '%s' has an empty element", when)
+		}
+	}
+
+	return c, nil
+}
+
+func (c *execCondition) lookup(values map[interface{}]interface{}) (interface{}, bool) {
+	var current interface{} = values
+	for _, elem := range c.path {
+		m, ok := current.(map[interface{}]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = m[elem]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func (c *execCondition) matches(values map[interface{}]interface{}) bool {
+	value, found := c.lookup(values)
+
+	switch c.operator {
+	case "==":
+		return found && fmt.Sprint(value) == c.value
+	case "!=":
+		return !found || fmt.Sprint(value) != c.value
+	}
+
+	return conditionTruthy(value, found) != c.negate
+}
+
+func conditionTruthy(value interface{}, found bool) bool {
+	if !found || value == nil {
+		return false
+	}
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return len(v) > 0 && v != "false"
+	case int:
+		return v != 0
+	case float64:
+		return v != 0
+	case []interface{}:
+		return len(v) > 0
+	case map[interface{}]interface{}:
+		return len(v) > 0
+	}
+	return true
+}
+
+func (e *ExecConfig) retryDelay() (time.Duration, error) {
+	if len(e.RetryDelay) == 0 {
+		return ExecRetryDelayDefault, nil
+	}
+	return time.ParseDuration(e.RetryDelay)
+}
+
+// ExecTimeoutError is returned by the Exec of a command implementation if
+// the exec did not finish within its timeout
+type ExecTimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *ExecTimeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s", e.Timeout)
+}
+
+// ExecError is returned if an exec exits with a non-zero exit code or
+// times out
+type ExecError struct {
+	Name     string
+	Argv     []string
+	ExitCode int
+	Attempts int
+	TimedOut *ExecTimeoutError
+
+	// Stderr contains the last lines written to stderr
+	Stderr []string
+}
+
+func (e *ExecError) Error() string {
+	execName := strings.Join(e.Argv, " ")
+	if len(e.Name) > 0 {
+		execName = fmt.Sprintf("%s (%s)", e.Name, execName)
+	}
+
+	msg := fmt.Sprintf("exec '%s' failed with exitcode=%d", execName, e.ExitCode)
+	if e.TimedOut != nil {
+		msg = fmt.Sprintf("exec '%s' %s", execName, e.TimedOut)
+	}
+	if e.Attempts > 1 {
+		msg += fmt.Sprintf(" after %d attempts", e.Attempts)
+	}
+	if len(e.Stderr) > 0 {
+		msg += fmt.Sprintf(", last lines of stderr:\n%s", strings.Join(e.Stderr, "\n"))
+	}
+	return msg
+}
+
+// stderrTail keeps the last lines of stderr of an exec
+type stderrTail struct {
+	lines []string
+	max   int
+}
+
+func (t *stderrTail) add(line string) {
+	t.lines = append(t.lines, line)
+	if len(t.lines) > t.max {
+		t.lines = t.lines[len(t.lines)-t.max:]
+	}
+}
@@ -0,0 +1,240 @@
+package slingshot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func prepareHostRunCommand(execs []ExecConfig) *Command {
+	resultFile := "result.yaml"
+	return &Command{
+		commandImplementation: &HostCommand{
+			BaseCommand: BaseCommand{
+				config: &CommandConfig{
+					Type:       "host",
+					ResultFile: &resultFile,
+					Execs:      execs,
+				},
+			},
+		},
+		provider: &MockProvider{},
+	}
+}
+
+func TestExecConfigParse(t *testing.T) {
+	c := &CommandConfig{}
+	err := yaml.Unmarshal([]byte(`execs:
+  - [vagrant, up]
+  - argv: [ansible-playbook, site.yml]
+    allowFailure: true
+    retries: 3
+    retryDelay: 10s`), c)
+	assert.Nil(t, err, "Unexpected error during parsing")
+
+	assert.Equal(t, []ExecConfig{
+		{Argv: []string{"vagrant", "up"}},
+		{Argv: []string{"ansible-playbook", "site.yml"}, AllowFailure: true, Retries: 3, RetryDelay: "10s"},
+	}, c.Execs)
+
+	err = yaml.Unmarshal([]byte(`execs:
+  - argv: [vagrant, up]
+    retryDelay: soon`), c)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "invalid retryDelay")
+
+	err = yaml.Unmarshal([]byte(`execs:
+  - retries: 1`), c)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "no argv")
+
+	err = yaml.Unmarshal([]byte(`execs:
+  - []`), c)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "no argv")
+
+	err = yaml.Unmarshal([]byte(`execs:
+  - argv: []`), c)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "no argv")
+}
+
+func TestCommandRunFailsOnExitCode(t *testing.T) {
+	c := prepareHostRunCommand([]ExecConfig{
+		{Argv: []string{"/bin/sh", "-c", "for i in $(seq 1 30); do echo line$i >&2; done; exit 3"}},
+		{Argv: []string{"/bin/sh", "-c", "echo not reached > result.yaml"}},
+	})
+
+	_, err := c.Run(nil)
+	execErr, ok := err.(*ExecError)
+	if assert.True(t, ok, "Expected an ExecError, got %v", err) {
+		assert.Equal(t, 3, execErr.ExitCode)
+		assert.Equal(t, 1, execErr.Attempts)
+		assert.Equal(t, "/bin/sh", execErr.Argv[0])
+		assert.Equal(t, ExecErrorStderrLines, len(execErr.Stderr))
+		assert.Equal(t, "line30", execErr.Stderr[len(execErr.Stderr)-1])
+		assert.Contains(t, execErr.Error(), "exitcode=3")
+	}
+}
+
+func TestCommandRunAllowFailure(t *testing.T) {
+	c := prepareHostRunCommand([]ExecConfig{
+		{Argv: []string{"/bin/sh", "-c", "exit 1"}, AllowFailure: true},
+		{Argv: []string{"/bin/sh", "-c", "echo done > result.yaml"}},
+	})
+
+	output, err := c.Run(nil)
+	assert.Nil(t, err, "Unexpected error during run")
+	assert.Equal(t, "done\n", string(output))
+}
+
+func TestCommandRunRetries(t *testing.T) {
+	var delays []time.Duration
+	execAfter = func(d time.Duration) <-chan time.Time {
+		delays = append(delays, d)
+		after := make(chan time.Time, 1)
+		after <- time.Now()
+		return after
+	}
+	defer func() {
+		execAfter = time.After
+	}()
+
+	// fails twice, succeeds on the third attempt
+	script := "n=$(cat attempts 2>/dev/null || echo 0); n=$((n+1)); echo $n > attempts; [ $n -ge 3 ] && echo $n > result.yaml"
+	c := prepareHostRunCommand([]ExecConfig{
+		{Argv: []string{"/bin/sh", "-c", script}, Retries: 3, RetryDelay: "1s"},
+	})
+
+	output, err := c.Run(nil)
+	assert.Nil(t, err, "Unexpected error during run")
+	assert.Equal(t, "3", strings.TrimSpace(string(output)))
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, delays)
+
+	delays = nil
+	c = prepareHostRunCommand([]ExecConfig{
+		{Argv: []string{"/bin/sh", "-c", "exit 2"}, Retries: 1},
+	})
+	_, err = c.Run(nil)
+	assert.Equal(t, fmt.Sprintf("exec '/bin/sh -c exit 2' failed with exitcode=2 after 2 attempts"), err.Error())
+	assert.Equal(t, []time.Duration{ExecRetryDelayDefault}, delays)
+}
+
+func TestExecConfigParseExtended(t *testing.T) {
+	c := &CommandConfig{}
+	err := yaml.Unmarshal([]byte(`execs:
+  - name: provision
+    argv: [ansible-playbook, site.yml]
+    env:
+      ANSIBLE_HOST_KEY_CHECKING: "False"
+    workDir: ansible
+    timeout: 30m
+    when: general.cloudProvider == aws`), c)
+	assert.Nil(t, err, "Unexpected error during parsing")
+
+	assert.Equal(t, []ExecConfig{{
+		Name:    "provision",
+		Argv:    []string{"ansible-playbook", "site.yml"},
+		Env:     map[string]string{"ANSIBLE_HOST_KEY_CHECKING": "False"},
+		WorkDir: "ansible",
+		Timeout: "30m",
+		When:    "general.cloudProvider == aws",
+	}}, c.Execs)
+	assert.Equal(t, "provision", c.Execs[0].String())
+
+	for _, invalid := range []struct {
+		exec     string
+		contains string
+	}{
+		{"timeout: long", "invalid timeout"},
+		{"workDir: ../other", "outside of the working dir"},
+		{"workDir: /etc", "outside of the working dir"},
+		{"when: general..cluster", "invalid condition"},
+		{"when: '!'", "invalid condition"},
+	} {
+		err = yaml.Unmarshal([]byte("execs:\n  - argv: [true]\n    "+invalid.exec), c)
+		if assert.NotNil(t, err, invalid.exec) {
+			assert.Contains(t, err.Error(), invalid.contains)
+		}
+	}
+}
+
+func TestExecConfigMatches(t *testing.T) {
+	parameters := []byte(`general:
+  cloudProvider: aws
+  cluster:
+    masters: 3
+    addons: false
+custom:
+  enabled: true
+`)
+
+	for when, expected := range map[string]bool{
+		"":                               true,
+		"custom.enabled":                 true,
+		"!custom.enabled":                false,
+		"custom.missing":                 false,
+		"!custom.missing":                true,
+		"general.cluster.addons":         false,
+		"general.cluster.masters":        true,
+		"general.cluster.masters == 3":   true,
+		"general.cloudProvider == aws":   true,
+		"general.cloudProvider != aws":   false,
+		"general.cloudProvider == 'gce'": false,
+		"custom.missing != aws":          true,
+	} {
+		e := &ExecConfig{Argv: []string{"true"}, When: when}
+		matches, err := e.Matches(&parameters)
+		assert.Nil(t, err, when)
+		assert.Equal(t, expected, matches, when)
+	}
+
+	e := &ExecConfig{Argv: []string{"true"}, When: "custom.enabled"}
+	matches, err := e.Matches(nil)
+	assert.Nil(t, err)
+	assert.False(t, matches)
+}
+
+func TestCommandRunExecOptions(t *testing.T) {
+	parameters := []byte("custom:\n  skip: true\n")
+	c := prepareHostRunCommand([]ExecConfig{
+		{Argv: []string{"mkdir", "sub"}},
+		{
+			Argv:    []string{"/bin/sh", "-c", "echo $GREETING > ../result.yaml"},
+			Env:     map[string]string{"GREETING": "hello"},
+			WorkDir: "sub",
+		},
+		{Argv: []string{"/bin/sh", "-c", "echo skipped > result.yaml"}, When: "!custom.skip"},
+	})
+
+	output, err := c.Run(&parameters)
+	assert.Nil(t, err, "Unexpected error during run")
+	assert.Equal(t, "hello\n", string(output))
+}
+
+func TestCommandRunExecTimeout(t *testing.T) {
+	c := prepareHostRunCommand([]ExecConfig{
+		{Name: "wait", Argv: []string{"sleep", "5"}, Timeout: "100ms"},
+	})
+
+	start := time.Now()
+	_, err := c.Run(nil)
+	assert.True(t, time.Since(start) < 5*time.Second, "exec has not been killed")
+
+	execErr, ok := err.(*ExecError)
+	if assert.True(t, ok, "Expected an ExecError, got %v", err) {
+		assert.NotNil(t, execErr.TimedOut)
+		assert.Equal(t, "exec 'wait (sleep 5)' timed out after 100ms", execErr.Error())
+	}
+
+	c = prepareHostRunCommand([]ExecConfig{
+		{Argv: []string{"sleep", "5"}, Timeout: "100ms", AllowFailure: true},
+		{Argv: []string{"/bin/sh", "-c", "echo done > result.yaml"}},
+	})
+	_, err = c.Run(nil)
+	assert.Nil(t, err, "Unexpected error during run")
+}
@@ -1,12 +1,15 @@
 package slingshot
 
 import (
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/simonswine/slingshot/pkg/utils"
 )
@@ -35,8 +38,13 @@ func (c *HostCommand) Prepare(parameters *[]byte) error {
 		return err
 	}
 
-	// untar work dir if needed
-	if c.config != nil && len(c.config.WorkingDirContent) != 0 {
+	// copy provider dir or untar work dir if needed
+	if c.config != nil && len(c.config.sourceDir) != 0 {
+		err = c.copySourceDir()
+		if err != nil {
+			return err
+		}
+	} else if c.config != nil && len(c.config.WorkingDirContent) != 0 {
 		err = utils.UnTarGz([]byte(c.config.WorkingDirContent), *c.tempWorkDir)
 		if err != nil {
 			return err
@@ -63,6 +71,21 @@ func (c *HostCommand) Prepare(parameters *[]byte) error {
 	return nil
 }
 
+func (c *HostCommand) copySourceDir() error {
+	objs, err := utils.WalkDirToObjects(c.config.sourceDir, c.config.sourceDir)
+	if err != nil {
+		return err
+	}
+
+	tarData, err := utils.TarListOfObjects(objs)
+	if err != nil {
+		return err
+	}
+
+	c.log().Debugf("copy provider dir '%s' to '%s'", c.config.sourceDir, *c.tempWorkDir)
+	return utils.UnTar(tarData, *c.tempWorkDir)
+}
+
 func (c *HostCommand) CleanUp() {
 	if c.oldWorkDir != nil {
 		err := os.Chdir(*c.oldWorkDir)
@@ -81,8 +104,12 @@ func (c *HostCommand) CleanUp() {
 	}
 }
 
-func (c *HostCommand) Exec(execSingle []string, stdout io.Writer, stderr io.Writer, stdin io.Reader) (exitCode int, err error) {
-	cmd := exec.Command(execSingle[0], execSingle[1:len(execSingle)]...)
+func (c *HostCommand) Exec(execSingle *ExecConfig, stdout io.Writer, stderr io.Writer, stdin io.Reader) (exitCode int, err error) {
+	if len(execSingle.Argv) == 0 {
+		return 0, fmt.Errorf("exec '%s' has no argv", execSingle.String())
+	}
+
+	cmd := exec.Command(execSingle.Argv[0], execSingle.Argv[1:len(execSingle.Argv)]...)
 	if stdout != nil {
 		cmd.Stdout = stdout
 
@@ -93,13 +120,69 @@ func (c *HostCommand) Exec(execSingle []string, stdout io.Writer, stderr io.Writ
 	if stdin != nil {
 		cmd.Stdin = stdin
 	}
+	if len(execSingle.Env) > 0 || (c.config != nil && len(c.config.environment) > 0) {
+		cmd.Env = os.Environ()
+		if c.config != nil {
+			cmd.Env = append(cmd.Env, environmentList(c.config.environment)...)
+		}
+		cmd.Env = append(cmd.Env, execSingle.EnvList()...)
+	}
+	if len(execSingle.WorkDir) > 0 && c.tempWorkDir != nil {
+		cmd.Dir = path.Join(*c.tempWorkDir, execSingle.WorkDir)
+	}
+
+	timeout, err := execSingle.timeout()
+	if err != nil {
+		return
+	}
 
+	execProcessGroup(cmd)
 	err = cmd.Start()
 	if err != nil {
 		return
 	}
 
+	// kill the process if it does not finish in time
+	var timedOut int32
+	if timeout > 0 {
+		timer := time.AfterFunc(timeout, func() {
+			atomic.StoreInt32(&timedOut, 1)
+			killExec(cmd)
+		})
+		defer timer.Stop()
+	}
+
+	// forward an interrupt of slingshot, kill the process if it does not
+	// stop within the grace period
+	var interrupted int32
+	exited := make(chan struct{})
+	defer close(exited)
+	go func() {
+		select {
+		case <-c.interrupted():
+		case <-exited:
+			return
+		}
+		atomic.StoreInt32(&interrupted, 1)
+		if err := interruptExec(cmd); err != nil {
+			killExec(cmd)
+			return
+		}
+		select {
+		case <-time.After(ExecInterruptGracePeriod):
+			c.log().Warnf("exec did not stop within %s, killing it", ExecInterruptGracePeriod)
+			killExec(cmd)
+		case <-exited:
+		}
+	}()
+
 	err = cmd.Wait()
+	if atomic.LoadInt32(&interrupted) == 1 {
+		return -1, ErrInterrupted
+	}
+	if atomic.LoadInt32(&timedOut) == 1 {
+		return -1, &ExecTimeoutError{Timeout: timeout}
+	}
 	if err != nil {
 
 		// detect right exitCode
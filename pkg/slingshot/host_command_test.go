@@ -1,6 +1,9 @@
 package slingshot
 
 import (
+	"io/ioutil"
+	"os"
+	"path"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -69,3 +72,30 @@ func TestHostCommandPersistence(t *testing.T) {
 	assert.Equal(t, "test654\n", stdout)
 
 }
+
+func TestHostCommandSourceDir(t *testing.T) {
+	sourceDir, err := ioutil.TempDir("", AppName)
+	assert.Nil(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(sourceDir)
+
+	err = os.Mkdir(path.Join(sourceDir, "playbooks"), 0755)
+	assert.Nil(t, err, "Unexpected error creating dir")
+	err = ioutil.WriteFile(path.Join(sourceDir, "playbooks", "site.yml"), []byte("test321\n"), 0644)
+	assert.Nil(t, err, "Unexpected error writing file")
+
+	c := &Command{
+		commandImplementation: &HostCommand{
+			BaseCommand: BaseCommand{
+				config: &CommandConfig{
+					Type:      "host",
+					sourceDir: sourceDir,
+				},
+			},
+		},
+	}
+
+	stdout, _, exitCode, err := c.Execute([]string{"cat", "playbooks/site.yml"})
+	assert.Nil(t, err, "Unexpected error during execution")
+	assert.Equal(t, 0, exitCode)
+	assert.Equal(t, "test321\n", stdout)
+}
@@ -0,0 +1,25 @@
+//go:build !windows
+// +build !windows
+
+package slingshot
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// execProcessGroup starts the exec in its own process group, so it is not
+// interrupted by the terminal but by slingshot after persisting its state
+func execProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// interruptExec sends an interrupt to all processes of the exec
+func interruptExec(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGINT)
+}
+
+// killExec kills all processes of the exec
+func killExec(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,20 @@
+//go:build windows
+// +build windows
+
+package slingshot
+
+import (
+	"os/exec"
+)
+
+func execProcessGroup(cmd *exec.Cmd) {
+}
+
+// interruptExec stops the exec, windows processes can not be interrupted
+func interruptExec(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
+
+func killExec(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
@@ -1,5 +0,0 @@
-package slingshot
-
-type InfrastructureProvider struct {
-	Provider
-}
@@ -0,0 +1,59 @@
+package slingshot
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// ErrInterrupted is returned by commands stopped by an interrupt of the user
+var ErrInterrupted = errors.New("interrupted by user")
+
+// ExecInterruptGracePeriod is the time an interrupted exec gets to stop
+// before it is killed
+const ExecInterruptGracePeriod = 10 * time.Second
+
+// handleInterrupts stops running provider commands on the first interrupt,
+// they persist their state and remove their containers. A second interrupt
+// quits immediately.
+func (s *Slingshot) handleInterrupts() {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-signals
+		s.log().Warn("interrupt received, stopping provider commands, interrupt again to quit immediately")
+		s.interrupt()
+
+		<-signals
+		s.log().Error("second interrupt received, quitting without cleanup")
+		os.Exit(130)
+	}()
+}
+
+func (s *Slingshot) interrupt() {
+	s.interruptOnce.Do(func() {
+		close(s.interrupted)
+	})
+}
+
+// Interrupted returns a channel that is closed once the user interrupts
+// slingshot
+func (s *Slingshot) Interrupted() <-chan struct{} {
+	if s == nil {
+		return nil
+	}
+	return s.interrupted
+}
+
+// isInterrupted checks without blocking if interrupted is closed
+func isInterrupted(interrupted <-chan struct{}) bool {
+	select {
+	case <-interrupted:
+		return true
+	default:
+		return false
+	}
+}
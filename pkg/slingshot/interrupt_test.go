@@ -0,0 +1,90 @@
+package slingshot
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandRunInterrupted(t *testing.T) {
+	provider := &MockProvider{interrupted: make(chan struct{})}
+	defer os.RemoveAll(path.Dir(provider.StatePath()))
+
+	resultFile := "result.yaml"
+	c := &Command{
+		commandImplementation: &HostCommand{
+			BaseCommand: BaseCommand{
+				config: &CommandConfig{
+					Type:         "host",
+					ResultFile:   &resultFile,
+					PersistPaths: []string{"state.txt"},
+					Execs: []ExecConfig{
+						{Argv: []string{"/bin/sh", "-c", "echo started > state.txt; sleep 5; echo finished > state.txt"}},
+						{Argv: []string{"/bin/sh", "-c", "echo not reached > result.yaml"}},
+					},
+				},
+				provider: provider,
+			},
+		},
+		provider: provider,
+	}
+
+	time.AfterFunc(200*time.Millisecond, func() {
+		close(provider.interrupted)
+	})
+
+	start := time.Now()
+	_, err := c.Run(nil)
+	assert.Equal(t, ErrInterrupted, err)
+	assert.True(t, time.Since(start) < 5*time.Second, "exec has not been interrupted")
+
+	// the state has been persisted on the way out
+	tarData, err := ioutil.ReadFile(provider.StatePath())
+	assert.Nil(t, err, "Unexpected error reading state")
+
+	stateDir, err := ioutil.TempDir("", AppName)
+	assert.Nil(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(stateDir)
+	c.commandImplementation.(*HostCommand).tempWorkDir = &stateDir
+	assert.Nil(t, c.commandImplementation.ExtractTar(tarData, ""))
+
+	state, err := ioutil.ReadFile(path.Join(stateDir, "state.txt"))
+	assert.Nil(t, err, "Unexpected error reading state file")
+	assert.Equal(t, "started\n", string(state))
+}
+
+func TestCommandRunInterruptedDuringRetryDelay(t *testing.T) {
+	provider := &MockProvider{interrupted: make(chan struct{})}
+	defer os.RemoveAll(path.Dir(provider.StatePath()))
+
+	c := prepareHostRunCommand([]ExecConfig{
+		{Argv: []string{"/bin/sh", "-c", "exit 1"}, Retries: 3, RetryDelay: "1h"},
+	})
+	c.provider = provider
+	c.commandImplementation.(*HostCommand).provider = provider
+
+	time.AfterFunc(200*time.Millisecond, func() {
+		close(provider.interrupted)
+	})
+
+	start := time.Now()
+	_, err := c.Run(nil)
+	assert.Equal(t, ErrInterrupted, err)
+	assert.True(t, time.Since(start) < 5*time.Second, "retry delay has not been interrupted")
+}
+
+func TestIsInterrupted(t *testing.T) {
+	s := &Slingshot{interrupted: make(chan struct{})}
+	assert.False(t, isInterrupted(s.Interrupted()))
+
+	s.interrupt()
+	s.interrupt()
+	assert.True(t, isInterrupted(s.Interrupted()))
+
+	var unset *Slingshot
+	assert.False(t, isInterrupted(unset.Interrupted()))
+}
@@ -0,0 +1,155 @@
+package slingshot
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v2"
+)
+
+const SlingshotKubeconfigFileName = "kubeconfig"
+
+type Kubeconfig struct {
+	ApiVersion     string                 `yaml:"apiVersion"`
+	Kind           string                 `yaml:"kind"`
+	Preferences    map[string]interface{} `yaml:"preferences,omitempty"`
+	Clusters       []KubeconfigCluster    `yaml:"clusters"`
+	Users          []KubeconfigUser       `yaml:"users"`
+	Contexts       []KubeconfigContext    `yaml:"contexts"`
+	CurrentContext string                 `yaml:"current-context"`
+
+	content []byte
+}
+
+type KubeconfigCluster struct {
+	Name    string                 `yaml:"name"`
+	Cluster map[string]interface{} `yaml:"cluster"`
+}
+
+type KubeconfigUser struct {
+	Name string                 `yaml:"name"`
+	User map[string]interface{} `yaml:"user"`
+}
+
+type KubeconfigContext struct {
+	Name    string                 `yaml:"name"`
+	Context map[string]interface{} `yaml:"context"`
+}
+
+func (c *KubeconfigContext) field(name string) string {
+	value, _ := c.Context[name].(string)
+	return value
+}
+
+func (k *Kubeconfig) Parse(content string) error {
+	err := yaml.Unmarshal([]byte(content), k)
+	k.content = []byte(content)
+	return err
+}
+
+// Content returns the kubeconfig as parsed, including fields not modelled
+// by Kubeconfig
+func (k *Kubeconfig) Content() []byte {
+	return k.content
+}
+
+func (k *Kubeconfig) Defaults() {
+	k.ApiVersion = "v1"
+	k.Kind = "Config"
+}
+
+// Merge adds the current context of k to the kubeconfig target, named after
+// name. Existing entries with the same name are replaced, everything else in
+// target is kept as it is.
+func (k *Kubeconfig) Merge(target map[interface{}]interface{}, name string) error {
+	contextName := k.CurrentContext
+	if len(contextName) == 0 && len(k.Contexts) == 1 {
+		contextName = k.Contexts[0].Name
+	}
+
+	var context *KubeconfigContext
+	for pos := range k.Contexts {
+		if k.Contexts[pos].Name == contextName {
+			context = &k.Contexts[pos]
+			break
+		}
+	}
+	if context == nil {
+		return fmt.Errorf("kubeconfig has no context '%s'", contextName)
+	}
+
+	var cluster *KubeconfigCluster
+	for pos := range k.Clusters {
+		if k.Clusters[pos].Name == context.field("cluster") {
+			cluster = &k.Clusters[pos]
+			break
+		}
+	}
+	if cluster == nil {
+		return fmt.Errorf("kubeconfig has no cluster '%s'", context.field("cluster"))
+	}
+
+	var user *KubeconfigUser
+	for pos := range k.Users {
+		if k.Users[pos].Name == context.field("user") {
+			user = &k.Users[pos]
+			break
+		}
+	}
+	if user == nil {
+		return fmt.Errorf("kubeconfig has no user '%s'", context.field("user"))
+	}
+
+	newContext := map[interface{}]interface{}{}
+	for key, value := range context.Context {
+		newContext[key] = value
+	}
+	newContext["cluster"] = name
+	newContext["user"] = name
+
+	for _, entry := range []struct {
+		listKey  string
+		entryKey string
+		value    interface{}
+	}{
+		{"clusters", "cluster", cluster.Cluster},
+		{"users", "user", user.User},
+		{"contexts", "context", newContext},
+	} {
+		if err := kubeconfigSetNamed(target, entry.listKey, name, entry.entryKey, entry.value); err != nil {
+			return err
+		}
+	}
+
+	if current, _ := target["current-context"].(string); len(current) == 0 {
+		target["current-context"] = name
+	}
+
+	return nil
+}
+
+// kubeconfigSetNamed replaces or appends the entry called name in the list
+// at listKey of a generic kubeconfig
+func kubeconfigSetNamed(target map[interface{}]interface{}, listKey string, name string, entryKey string, value interface{}) error {
+	var list []interface{}
+	if existing, ok := target[listKey]; ok && existing != nil {
+		if list, ok = existing.([]interface{}); !ok {
+			return fmt.Errorf("kubeconfig field '%s' is not a list", listKey)
+		}
+	}
+
+	for _, item := range list {
+		entry, ok := item.(map[interface{}]interface{})
+		if !ok || entry["name"] != name {
+			continue
+		}
+		entry[entryKey] = value
+		target[listKey] = list
+		return nil
+	}
+
+	target[listKey] = append(list, map[interface{}]interface{}{
+		"name":   name,
+		entryKey: value,
+	})
+	return nil
+}
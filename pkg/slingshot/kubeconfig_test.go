@@ -0,0 +1,161 @@
+package slingshot
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/simonswine/slingshot/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func TestKubeconfigMerge(t *testing.T) {
+	source := &Kubeconfig{}
+	err := source.Parse(`apiVersion: v1
+kind: Config
+clusters:
+- name: local
+  cluster:
+    server: https://192.168.51.51:443
+users:
+- name: admin
+  user:
+    token: secret
+contexts:
+- name: default
+  context:
+    cluster: local
+    user: admin
+current-context: default`)
+	assert.Nil(t, err, "Unexpected error during parsing")
+
+	target := map[interface{}]interface{}{}
+	err = yaml.Unmarshal([]byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: other
+  cluster:
+    server: https://10.0.0.1:443
+    extensions:
+    - name: other-extension
+- name: test
+  cluster:
+    server: https://10.0.0.2:443
+contexts:
+- name: other
+  context:
+    cluster: other
+    user: other
+    extensions:
+    - name: context-extension
+users:
+- name: other
+  user:
+    auth-provider:
+      name: gcp
+      config:
+        access-token: token
+extensions:
+- name: top-level-extension
+current-context: other`), &target)
+	assert.Nil(t, err, "Unexpected error during parsing")
+
+	err = source.Merge(target, "test")
+	assert.Nil(t, err, "Unexpected error during merge")
+
+	merged := &Kubeconfig{}
+	content, err := yaml.Marshal(target)
+	assert.Nil(t, err)
+	assert.Nil(t, merged.Parse(string(content)))
+
+	assert.Equal(t, 2, len(merged.Clusters))
+	assert.Equal(t, "https://192.168.51.51:443", merged.Clusters[1].Cluster["server"])
+	assert.Equal(t, 2, len(merged.Users))
+	assert.Equal(t, "test", merged.Users[1].Name)
+	assert.Equal(t, 2, len(merged.Contexts))
+	assert.Equal(t, "test", merged.Contexts[1].Context["cluster"])
+	assert.Equal(t, "test", merged.Contexts[1].Context["user"])
+	assert.Equal(t, "other", merged.CurrentContext)
+
+	// fields slingshot does not model are kept
+	for _, field := range []string{
+		"other-extension",
+		"context-extension",
+		"top-level-extension",
+		"access-token: token",
+	} {
+		assert.Contains(t, string(content), field)
+	}
+}
+
+func TestClusterKubeconfigStored(t *testing.T) {
+	configDir, err := ioutil.TempDir("", AppName)
+	assert.Nil(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(configDir)
+
+	// the stored kubeconfig is read without pulling the provider image
+	c := NewCluster(&Slingshot{configDir: configDir, pullPolicy: PullPolicyNever})
+	c.Name = "test"
+	c.Stages = []*ClusterStage{{Name: "config", Image: "example/not-available:1.0"}}
+	assert.Nil(t, c.writeKubeconfig([]byte("apiVersion: v1\nkind: Config\ncurrent-context: default\nextensions: []\n")))
+
+	kubeconfig, errs := c.Kubeconfig(nil)
+	assert.Empty(t, errs)
+	assert.Equal(t, "default", kubeconfig.CurrentContext)
+	assert.Contains(t, string(kubeconfig.Content()), "extensions")
+}
+
+const kubeconfigDiscover = `provider:
+  type: config
+  version: "1"
+commands:
+  apply:
+    type: host
+    parameterFile: parameters.yaml
+    resultFile: result.yaml
+    execs:
+      - [cp, parameters.yaml, result.yaml]
+  kubeconfig:
+    type: host
+    resultFile: kubeconfig.yaml
+    execs:
+      - [cp, rotated.yaml, kubeconfig.yaml]
+`
+
+func TestClusterKubeconfigCommand(t *testing.T) {
+	configDir, err := ioutil.TempDir("", AppName)
+	assert.Nil(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(configDir)
+
+	sourceDir := path.Join(configDir, "provider")
+	assert.Nil(t, utils.EnsureDirectory(sourceDir))
+	err = ioutil.WriteFile(path.Join(sourceDir, ProviderDirDiscoverFile), []byte(kubeconfigDiscover), 0644)
+	assert.Nil(t, err, "Unexpected error writing file")
+	err = ioutil.WriteFile(path.Join(sourceDir, "rotated.yaml"), []byte("apiVersion: v1\nkind: Config\ncurrent-context: rotated\n"), 0644)
+	assert.Nil(t, err, "Unexpected error writing file")
+
+	c := NewCluster(&Slingshot{configDir: configDir})
+	c.Name = "test"
+	c.Parameters = &Parameters{}
+	c.Stages = []*ClusterStage{{Name: "config", Image: ProviderDirScheme + sourceDir}}
+	assert.Nil(t, c.writeKubeconfig([]byte("apiVersion: v1\nkind: Config\ncurrent-context: expired\n")))
+
+	// the stored kubeconfig is used until the command is known
+	kubeconfig, errs := c.Kubeconfig(nil)
+	assert.Empty(t, errs)
+	assert.Equal(t, "expired", kubeconfig.CurrentContext)
+
+	_, errs = c.initProviders()
+	assert.Empty(t, errs)
+	assert.True(t, c.Stages[0].KubeconfigCommand)
+
+	// the command is run and its output stored
+	kubeconfig, errs = c.Kubeconfig(nil)
+	assert.Empty(t, errs)
+	assert.Equal(t, "rotated", kubeconfig.CurrentContext)
+	content, err := ioutil.ReadFile(c.kubeconfigFilePath())
+	assert.Nil(t, err, "Unexpected error reading stored kubeconfig")
+	assert.Contains(t, string(content), "rotated")
+}
@@ -2,11 +2,20 @@ package slingshot
 
 import (
 	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"sort"
 
+	"github.com/simonswine/slingshot/pkg/utils"
 	"golang.org/x/crypto/ssh"
 	"gopkg.in/yaml.v2"
 )
 
+var KubernetesNetworkingPlugins = []string{"flannel", "calico", "weave"}
+
+var domainNameRegexp = regexp.MustCompile(`^([a-z0-9]([-a-z0-9]*[a-z0-9])?\.)*[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
 type Parameters struct {
 	General   ParametersGeneral
 	Inventory []ParameterInventory
@@ -17,6 +26,44 @@ func (p *Parameters) Parse(content string) error {
 	return err
 }
 
+// Merge deep merges the YAML content over the current parameters, keys
+// without a matching parameter are reported as errors
+func (p *Parameters) Merge(content string) (errs []error) {
+	overlay := map[interface{}]interface{}{}
+	if err := yaml.Unmarshal([]byte(content), &overlay); err != nil {
+		return []error{err}
+	}
+
+	for _, key := range utils.YamlUnknownKeys(overlay, reflect.TypeOf(p), "") {
+		errs = append(errs, fieldError(key, "unknown parameter"))
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	currentBytes, err := yaml.Marshal(p)
+	if err != nil {
+		return []error{err}
+	}
+	base := map[interface{}]interface{}{}
+	if err := yaml.Unmarshal(currentBytes, &base); err != nil {
+		return []error{err}
+	}
+
+	mergedBytes, err := yaml.Marshal(utils.YamlMerge(base, overlay))
+	if err != nil {
+		return []error{err}
+	}
+
+	merged := Parameters{}
+	if err := merged.Parse(string(mergedBytes)); err != nil {
+		return []error{err}
+	}
+	*p = merged
+
+	return
+}
+
 func (p *Parameters) Validate() (errs []error) {
 	errs = append(errs, p.General.Validate()...)
 	errs = append(errs, p.validateInventory()...)
@@ -24,8 +71,8 @@ func (p *Parameters) Validate() (errs []error) {
 }
 
 func (p *Parameters) validateInventory() (errs []error) {
-	for _, elem := range p.Inventory {
-		errs = append(errs, elem.Validate()...)
+	for pos, elem := range p.Inventory {
+		errs = append(errs, elem.Validate(fmt.Sprintf("inventory[%d]", pos))...)
 	}
 	return
 }
@@ -87,9 +134,36 @@ func (pC *ParametersCluster) Defaults() {
 	}
 }
 
-func (pC *ParametersCluster) ValidateMachines() (errs []error) {
+// MachineCount returns the number of machines with the given role
+func (pC *ParametersCluster) MachineCount(role string) (count int) {
 	for _, elem := range pC.Machines {
-		errs = append(errs, elem.Validate()...)
+		if elem.Roles == nil {
+			continue
+		}
+		for _, r := range *elem.Roles {
+			if r == role {
+				count += elem.Count
+				break
+			}
+		}
+	}
+	return
+}
+
+func (pC *ParametersCluster) ValidateMachines() (errs []error) {
+	var names []string
+	for name := range pC.Machines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		elem := pC.Machines[name]
+		errs = append(errs, elem.Validate(fmt.Sprintf("general.cluster.machines.%s", name))...)
+	}
+
+	if pC.MachineCount("masters") < 1 {
+		errs = append(errs, fieldError("general.cluster.machines", "at least one machine with the role 'masters' is required"))
 	}
 	return
 }
@@ -120,8 +194,18 @@ func (pM *ParameterMachine) Defaults() {
 	pM.InstanceType = &instanceType
 }
 
-func (pM *ParameterMachine) Validate() (errs []error) {
-	// TODO: Implement some validations
+func (pM *ParameterMachine) Validate(path string) (errs []error) {
+	if pM.Count < 0 {
+		errs = append(errs, fieldError(path+".count", "must not be negative, got %d", pM.Count))
+	} else if pM.Count == 0 {
+		errs = append(errs, fieldWarning(path+".count", "no machines will be created"))
+	}
+	if pM.Cores != nil && *pM.Cores < 1 {
+		errs = append(errs, fieldError(path+".cores", "must be at least 1, got %d", *pM.Cores))
+	}
+	if pM.Memory != nil && *pM.Memory < 1 {
+		errs = append(errs, fieldError(path+".memory", "must be at least 1, got %d", *pM.Memory))
+	}
 	return
 }
 
@@ -167,11 +251,64 @@ func (pK *ParametersKubernetes) Defaults() {
 }
 
 func (pK *ParametersKubernetes) Validate() (errs []error) {
-	errs = append(errs, pK.validateFlannel()...)
+	path := "general.cluster.kubernetes"
+
+	if pK.MasterApiPort < 1 || pK.MasterApiPort > 65535 {
+		errs = append(errs, fieldError(path+".masterApiPort", "invalid port %d", pK.MasterApiPort))
+	}
+
+	if _, _, err := net.ParseCIDR(pK.ServiceNetwork); err != nil {
+		errs = append(errs, fieldError(path+".serviceNetwork", "invalid network '%s'", pK.ServiceNetwork))
+	}
+
+	if pK.Dns.Replicas < 1 {
+		errs = append(errs, fieldError(path+".dns.replicas", "must be at least 1, got %d", pK.Dns.Replicas))
+	}
+	if !domainNameRegexp.MatchString(pK.Dns.DomainName) {
+		errs = append(errs, fieldError(path+".dns.domainName", "invalid domain name '%s'", pK.Dns.DomainName))
+	}
+
+	known := false
+	for _, networking := range KubernetesNetworkingPlugins {
+		if pK.Networking == networking {
+			known = true
+			break
+		}
+	}
+	if !known {
+		errs = append(errs, fieldError(path+".networking", "unknown plugin '%s', supported are %v", pK.Networking, KubernetesNetworkingPlugins))
+	}
+
+	if pK.Networking == "flannel" {
+		errs = append(errs, pK.validateFlannel()...)
+	}
 	return
 }
 
 func (pK *ParametersKubernetes) validateFlannel() (errs []error) {
+	path := "general.cluster.kubernetes.flannel"
+
+	if pK.Flannel.Prefix < 1 || pK.Flannel.Prefix > 32 {
+		errs = append(errs, fieldError(path+".prefix", "invalid prefix %d", pK.Flannel.Prefix))
+		return
+	}
+	if pK.Flannel.HostPrefix <= pK.Flannel.Prefix || pK.Flannel.HostPrefix > 32 {
+		errs = append(errs, fieldError(path+".hostPrefix", "must be larger than prefix %d and at most 32, got %d", pK.Flannel.Prefix, pK.Flannel.HostPrefix))
+	}
+
+	_, flannelNet, err := net.ParseCIDR(fmt.Sprintf("%s/%d", pK.Flannel.Subnet, pK.Flannel.Prefix))
+	if err != nil || net.ParseIP(pK.Flannel.Subnet).To4() == nil {
+		errs = append(errs, fieldError(path+".subnet", "invalid subnet '%s'", pK.Flannel.Subnet))
+		return
+	}
+
+	_, serviceNet, err := net.ParseCIDR(pK.ServiceNetwork)
+	if err != nil {
+		return
+	}
+	if flannelNet.Contains(serviceNet.IP) || serviceNet.Contains(flannelNet.IP) {
+		errs = append(errs, fieldError(path+".subnet", "network %s overlaps with service network %s", flannelNet, serviceNet))
+	}
 	return
 }
 
@@ -197,12 +334,14 @@ func (pA *ParametersAuthentication) getPubKey() (pubKey string, err error) {
 }
 
 func (pA *ParametersAuthentication) Validate() (errs []error) {
+	path := "general.authentication.ssh"
+
 	if pA.Ssh.PrivateKey == nil {
-		errs = append(errs, fmt.Errorf("Please provide a private key in general.authentication.privateKey"))
+		errs = append(errs, fieldError(path+".privateKey", "please provide a private key"))
 	} else if pA.Ssh.PubKey == nil {
 		pubKey, err := pA.getPubKey()
 		if err != nil {
-			errs = append(errs, err)
+			errs = append(errs, fieldError(path+".privateKey", "invalid private key: %s", err))
 		} else {
 			pA.Ssh.PubKey = &pubKey
 		}
@@ -220,14 +359,30 @@ type ParameterInventory struct {
 	PublicIP  *string `yaml:"publicIP"`
 	PrivateIP *string `yaml:"privateIP"`
 	Roles     []string
+
+	// HostKey is the public ssh host key in authorized_keys format
+	HostKey *string `yaml:"hostKey,omitempty"`
 }
 
-func (pI *ParameterInventory) Validate() (errs []error) {
+func (pI *ParameterInventory) Validate(path string) (errs []error) {
+	if pI.Name == nil {
+		errs = append(errs, fieldWarning(path+".name", "host has no name"))
+	}
 	if pI.PrivateIP == nil {
-		errs = append(errs, fmt.Errorf("Required PrivateIP field missing"))
+		errs = append(errs, fieldError(path+".privateIP", "required field missing"))
+	} else if net.ParseIP(*pI.PrivateIP) == nil {
+		errs = append(errs, fieldError(path+".privateIP", "invalid IP address '%s'", *pI.PrivateIP))
+	}
+	if pI.PublicIP != nil && net.ParseIP(*pI.PublicIP) == nil {
+		errs = append(errs, fieldError(path+".publicIP", "invalid IP address '%s'", *pI.PublicIP))
 	}
 	if len(pI.Roles) < 1 {
-		errs = append(errs, fmt.Errorf("You need to specify at least on role"))
+		errs = append(errs, fieldError(path+".roles", "you need to specify at least one role"))
+	}
+	if pI.HostKey != nil {
+		if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(*pI.HostKey)); err != nil {
+			errs = append(errs, fieldError(path+".hostKey", "invalid host key: %s", err))
+		}
 	}
 	return
 }
@@ -1,6 +1,8 @@
 package slingshot
 
 import (
+	"fmt"
+	"io/ioutil"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -89,7 +91,7 @@ func TestParametersCluster(t *testing.T) {
     dns:
       domainName: cluster.swine.de
       replicas: 3
-    networking: noflannel
+    networking: calico
     flannel:
       subnet: 172.17.0.0
       prefix: 15
@@ -117,7 +119,7 @@ func TestParametersCluster(t *testing.T) {
 	assert.Equal(t, "cluster.swine.de", p.Cluster.Kubernetes.Dns.DomainName)
 	assert.Equal(t, 3, p.Cluster.Kubernetes.Dns.Replicas)
 
-	assert.Equal(t, "noflannel", p.Cluster.Kubernetes.Networking)
+	assert.Equal(t, "calico", p.Cluster.Kubernetes.Networking)
 
 	assert.Equal(t, "172.17.0.0", p.Cluster.Kubernetes.Flannel.Subnet)
 	assert.Equal(t, 15, p.Cluster.Kubernetes.Flannel.Prefix)
@@ -176,3 +178,99 @@ func TestParametersAuthentication(t *testing.T) {
 	// ensure no validation erros
 	assert.Equal(t, []error(nil), valErrs)
 }
+
+func TestParametersMerge(t *testing.T) {
+	p := &Parameters{}
+	p.Defaults()
+
+	errs := p.Merge(`general:
+  cluster:
+    kubernetes:
+      serviceNetwork: 10.240.0.0/16
+      addons:
+        kubeDash: true
+    machines:
+      worker:
+        count: 5`)
+	assert.Equal(t, []error(nil), errs)
+
+	assert.Equal(t, "10.240.0.0/16", p.General.Cluster.Kubernetes.ServiceNetwork)
+	assert.Equal(t, 443, p.General.Cluster.Kubernetes.MasterApiPort)
+	assert.True(t, p.General.Cluster.Kubernetes.Addons.KubeDash)
+	assert.Equal(t, 5, p.General.Cluster.Machines["worker"].Count)
+	assert.Equal(t, 2, *p.General.Cluster.Machines["worker"].Cores)
+	assert.Equal(t, 1, p.General.Cluster.Machines["master"].Count)
+	assert.Equal(t, "root", *p.General.Authentication.Ssh.User)
+}
+
+func TestParametersMergeUnknownKeys(t *testing.T) {
+	p := &Parameters{}
+	p.Defaults()
+
+	errs := p.Merge(`general:
+  cluster:
+    kubernetes:
+      mastersCount: 3
+    machines:
+      worker:
+        cpus: 4`)
+
+	assert.Equal(t, 2, len(errs))
+	assert.Contains(t, fmt.Sprintf("%s", errs), "general.cluster.kubernetes.mastersCount")
+	assert.Contains(t, fmt.Sprintf("%s", errs), "general.cluster.machines.worker.cpus")
+	assert.Equal(t, 2, p.General.Cluster.Machines["worker"].Count)
+}
+
+func TestParametersMergeExample(t *testing.T) {
+	content, err := ioutil.ReadFile("../../examples/yaml/parameters.yaml")
+	assert.Nil(t, err, "Unexpected error during reading example")
+
+	p := &Parameters{}
+	p.Defaults()
+
+	errs := p.Merge(string(content))
+	assert.Equal(t, []error(nil), errs)
+}
+
+func TestParametersClusterInvalid(t *testing.T) {
+	yamlContent := `cluster:
+  kubernetes:
+    masterApiPort: 70000
+    serviceNetwork: 172.16.0.0/12
+    dns:
+      domainName: -cluster.local
+      replicas: 0
+    networking: flannel
+    flannel:
+      subnet: 172.17.0.0
+      prefix: 16
+      hostPrefix: 16
+  machines:
+    master:
+      count: 0
+      roles:
+        - masters
+    worker:
+      count: -1
+      roles:
+        - workers`
+
+	p := &ParametersGeneral{}
+	p.Defaults()
+	err := p.Parse(yamlContent)
+	assert.Nil(t, err, "Unexpected error during parsing")
+
+	valErrs := fmt.Sprintf("%s", p.Cluster.Validate())
+
+	for _, path := range []string{
+		"general.cluster.kubernetes.masterApiPort",
+		"general.cluster.kubernetes.dns.domainName",
+		"general.cluster.kubernetes.dns.replicas",
+		"general.cluster.kubernetes.flannel.hostPrefix",
+		"general.cluster.kubernetes.flannel.subnet",
+		"general.cluster.machines.worker.count",
+		"general.cluster.machines:",
+	} {
+		assert.Contains(t, valErrs, path)
+	}
+}
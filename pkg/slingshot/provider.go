@@ -2,29 +2,77 @@ package slingshot
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/fsouza/go-dockerclient"
+	"github.com/simonswine/slingshot/pkg/utils"
 	"gopkg.in/yaml.v2"
 	"path"
+	"strings"
 )
 
 type Provider struct {
-	imageRepo    string
-	imageTag     string
-	imageId      *string
-	containerId  *string
-	providerType string
-	docker       *docker.Client
-	cluster      *Cluster
-	config       ProviderConfig
+	imageRepo      string
+	imageTag       string
+	imageDigest    string
+	repoDigest     string
+	pullPolicy     string
+	sourceDir      string
+	imageId        *string
+	containerId    *string
+	providerType   string
+	stageType      string
+	legacyContract bool
+	docker         *docker.Client
+	cluster        *Cluster
+	config         ProviderConfig
+
+	// environmentSources are configured by the user of the cluster,
+	// environmentValues caches the resolved values
+	environmentSources map[string]*EnvironmentSource
+	environmentValues  map[string]string
 }
 
+// ProviderDirScheme prefixes providers loaded from a local directory
+const ProviderDirScheme = "dir://"
+
+// ProviderDirDiscoverFile contains the discover output of providers loaded
+// from a local directory
+const ProviderDirDiscoverFile = "discover.yaml"
+
+const (
+	PullPolicyAlways       = "always"
+	PullPolicyIfNotPresent = "if-not-present"
+	PullPolicyNever        = "never"
+)
+
+var PullPolicies = []string{PullPolicyAlways, PullPolicyIfNotPresent, PullPolicyNever}
+
+func ValidatePullPolicy(policy string) error {
+	for _, known := range PullPolicies {
+		if policy == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown pull policy '%s', supported are %v", policy, PullPolicies)
+}
+
+// ProviderContractVersions are the provider contract versions supported
+var ProviderContractVersions = []string{"1"}
+
+// ProviderTypes are the well-known types a provider can declare, providers
+// of other stages declare the type of their stage
+var ProviderTypes = []string{"infrastructure", "config"}
+
 type ProviderInterface interface {
 	StatePath() string
 	Log() *log.Entry
 	Docker() *docker.Client
 	DockerImageId() *string
+	Interrupted() <-chan struct{}
 }
 
 type ProviderConfig struct {
@@ -42,6 +90,44 @@ func (c *ProviderConfig) Parse(content string) error {
 	return err
 }
 
+// Declared is true if the provider declares its type and contract version
+func (c *ProviderConfig) Declared() bool {
+	return len(c.Provider.Type) > 0 || len(c.Provider.Version) > 0
+}
+
+// Validate checks that the provider can be used as providerType provider by
+// this version of slingshot
+func (c *ProviderConfig) Validate(providerType string) error {
+	if !c.Declared() {
+		return fmt.Errorf(
+			"provider does not declare 'provider.type' and 'provider.version' in its discover output, please use a provider implementing contract version %s",
+			strings.Join(ProviderContractVersions, ", "),
+		)
+	}
+
+	if c.Provider.Type != providerType {
+		return fmt.Errorf(
+			"provider declares type '%s' but is used as %s provider, please check the providers of the cluster's stages",
+			c.Provider.Type,
+			providerType,
+		)
+	}
+
+	for _, version := range ProviderContractVersions {
+		if c.Provider.Version == version {
+			return nil
+		}
+	}
+	return fmt.Errorf(
+		"provider implements contract version '%s', but %s %s supports %s, please upgrade %s or use a provider release implementing a supported contract",
+		c.Provider.Version,
+		AppName,
+		AppVersion,
+		strings.Join(ProviderContractVersions, ", "),
+		AppName,
+	)
+}
+
 func (p *Provider) init(name string) {
 	p.providerType = name
 
@@ -69,14 +155,37 @@ func (p *Provider) Log() *log.Entry {
 }
 
 func (p *Provider) RunCommand(commandName string, parameters *[]byte) (output []byte, err error) {
+	return p.runCommand(commandName, parameters, false)
+}
+
+// RunCommandReadOnly runs a command with the persisted state restored, but
+// without storing the state after the command has finished
+func (p *Provider) RunCommandReadOnly(commandName string, parameters *[]byte) (output []byte, err error) {
+	return p.runCommand(commandName, parameters, true)
+}
+
+func (p *Provider) runCommand(commandName string, parameters *[]byte, readOnlyState bool) (output []byte, err error) {
 	p.Log().Debugf("running command '%s'", commandName)
 
 	if commandDef, ok := p.config.Commands[commandName]; ok {
+		// commands without own persist paths share the state of apply
+		if applyDef, ok := p.config.Commands["apply"]; ok && len(commandDef.PersistPaths) == 0 {
+			commandDef.PersistPaths = applyDef.PersistPaths
+		}
+
+		env, errEnv := p.environment(commandName, &commandDef)
+		if errEnv != nil {
+			err = errEnv
+			return
+		}
+		commandDef.environment = env
+
 		c, errCmd := NewCommand(&commandDef, p)
 		if errCmd != nil {
 			err = errCmd
 			return
 		}
+		c.readOnlyState = readOnlyState
 		return c.Run(parameters)
 
 	}
@@ -85,27 +194,97 @@ func (p *Provider) RunCommand(commandName string, parameters *[]byte) (output []
 	return
 }
 
+// Interrupted returns a channel that is closed once the user interrupts
+// slingshot
+func (p *Provider) Interrupted() <-chan struct{} {
+	if p.cluster == nil {
+		return nil
+	}
+	return p.cluster.slingshot.Interrupted()
+}
+
+func (p *Provider) HasCommand(commandName string) bool {
+	_, ok := p.config.Commands[commandName]
+	return ok
+}
+
 func (p *Provider) pullImage() error {
-	// TODO: Support private reg auth
-	//auth, err := docker.NewAuthConfigurationsFromDockerCfg()
-	//if err != nil {
-	//	return err
-	//}
+	registry := RegistryFromRepository(p.imageRepo)
+
+	// credentials of another registry can be pinned per cluster
+	authRegistry := registry
+	if p.cluster != nil {
+		authRegistry = p.cluster.registryAuth(registry)
+	}
 
-	return p.docker.PullImage(
+	// a broken docker config should not prevent pulling public images
+	var auth docker.AuthConfiguration
+	dockerConfig, err := LoadDockerConfigFile()
+	if err == nil {
+		auth, err = dockerConfig.AuthConfiguration(authRegistry)
+	}
+	if err != nil {
+		p.Log().Warnf("pulling image '%s' anonymously, cannot read credentials for registry '%s': %s", p.ImageName(), authRegistry, err)
+		auth = docker.AuthConfiguration{}
+	}
+	if len(auth.Username) > 0 {
+		p.Log().Debugf("using credentials of user '%s' for registry '%s'", auth.Username, authRegistry)
+	}
+
+	// show pull progress in log
+	progress := utils.NewLineWriter(func(line string) {
+		p.Log().Info(line)
+	})
+	defer progress.Flush()
+
+	err = p.docker.PullImage(
 		docker.PullImageOptions{
-			Repository: p.imageRepo,
-			Tag:        p.imageTag,
+			Repository:   p.imageRepo,
+			Tag:          p.imageReference(),
+			OutputStream: progress,
 		},
-		docker.AuthConfiguration{},
+		auth,
 	)
+	if err != nil {
+		return newImagePullError(p.ImageName(), registry, err)
+	}
+	return nil
 }
 
+// listImages returns the local images exactly matching the tag or digest of
+// the provider, the same image is only returned once
 func (p *Provider) listImages() ([]docker.APIImages, error) {
-	return p.docker.ListImages(docker.ListImagesOptions{
-		All:    false,
-		Filter: p.ImageName(),
+	list, err := p.docker.ListImages(docker.ListImagesOptions{
+		All:     false,
+		Digests: true,
+		Filter:  p.imageRepo,
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	return matchImages(list, p.ImageName(), len(p.imageDigest) > 0), nil
+}
+
+func matchImages(list []docker.APIImages, imageName string, byDigest bool) (matching []docker.APIImages) {
+	seen := map[string]bool{}
+
+	for _, image := range list {
+		references := image.RepoTags
+		if byDigest {
+			references = image.RepoDigests
+		}
+
+		for _, reference := range references {
+			if reference == imageName && !seen[image.ID] {
+				matching = append(matching, image)
+				seen[image.ID] = true
+				break
+			}
+		}
+	}
+
+	return
 }
 
 func (p *Provider) Docker() *docker.Client {
@@ -139,7 +318,13 @@ func (p *Provider) getImage() (string, error) {
 		return "", err
 	}
 
-	if len(list) == 0 {
+	if p.pullPolicy == PullPolicyNever && len(list) == 0 {
+		err = fmt.Errorf("image '%s' is not available locally and pull policy is '%s'", p.ImageName(), PullPolicyNever)
+		p.Log().Error(err)
+		return "", err
+	}
+
+	if (len(list) == 0 && p.pullPolicy != PullPolicyNever) || p.pullPolicy == PullPolicyAlways {
 		p.Log().Debugf("pulling image from registry")
 
 		err = p.pullImage()
@@ -161,15 +346,76 @@ func (p *Provider) getImage() (string, error) {
 
 	}
 
-	err = fmt.Errorf("This should never happen: more than a one image found (%d)", len(list))
+	if len(list) == 0 {
+		err = fmt.Errorf("image '%s' not found locally", p.ImageName())
+	} else {
+		var ids []string
+		for _, image := range list {
+			ids = append(ids, image.ID)
+		}
+		err = fmt.Errorf("image '%s' is ambiguous, it matches the images %s", p.ImageName(), strings.Join(ids, ", "))
+	}
 	p.Log().Error(err)
 	return "", err
 }
 
 func (p *Provider) ImageName() string {
+	if len(p.sourceDir) > 0 {
+		return ProviderDirScheme + p.sourceDir
+	}
+	if len(p.imageDigest) > 0 {
+		return fmt.Sprintf("%s@%s", p.imageRepo, p.imageDigest)
+	}
 	return fmt.Sprintf("%s:%s", p.imageRepo, p.imageTag)
 }
 
+// imageReference returns the pinned digest or the tag of the image
+func (p *Provider) imageReference() string {
+	if len(p.imageDigest) > 0 {
+		return p.imageDigest
+	}
+	return p.imageTag
+}
+
+// Digest returns the content digest of the image, empty if the image has
+// not been pulled from a registry
+func (p *Provider) Digest() string {
+	if len(p.imageDigest) > 0 {
+		return p.imageDigest
+	}
+	return p.repoDigest
+}
+
+func (p *Provider) resolveDigest() error {
+	image, err := p.docker.InspectImage(*p.imageId)
+	if err != nil {
+		return err
+	}
+
+	for _, repoDigest := range image.RepoDigests {
+		if strings.HasPrefix(repoDigest, p.imageRepo+"@") {
+			p.repoDigest = strings.TrimPrefix(repoDigest, p.imageRepo+"@")
+			return nil
+		}
+	}
+
+	p.Log().Warn("image has no digest, it cannot be pinned")
+	return nil
+}
+
+// ParseImageName splits an image name into repository, tag and digest
+func ParseImageName(imageName string) (repo string, tag string, digest string) {
+	if pos := strings.Index(imageName, "@"); pos >= 0 {
+		return imageName[:pos], "", imageName[pos+1:]
+	}
+
+	repo, tag = docker.ParseRepositoryTag(imageName)
+	if len(tag) == 0 {
+		tag = "latest"
+	}
+	return
+}
+
 func (p *Provider) readConfig() error {
 
 	c, err := NewCommand(
@@ -193,13 +439,64 @@ func (p *Provider) readConfig() error {
 	return p.config.Parse(stdOut)
 }
 
+// initDir reads the provider config from a local directory, that is used
+// as working dir of the provider's host commands
+func (p *Provider) initDir(dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	p.sourceDir = dir
+
+	if stat, err := os.Stat(dir); err != nil {
+		return err
+	} else if !stat.IsDir() {
+		return fmt.Errorf("provider path '%s' is not a directory", dir)
+	}
+
+	discoverPath := filepath.Join(dir, ProviderDirDiscoverFile)
+	content, err := ioutil.ReadFile(discoverPath)
+	if err != nil {
+		return fmt.Errorf("Error while reading provider config '%s': %s", discoverPath, err)
+	}
+
+	if err := p.config.Parse(string(content)); err != nil {
+		return err
+	}
+
+	for commandName, commandDef := range p.config.Commands {
+		if commandDef.Type != "host" {
+			return fmt.Errorf("command '%s' has type '%s', providers loaded from a directory only support 'host' commands", commandName, commandDef.Type)
+		}
+		commandDef.sourceDir = dir
+		p.config.Commands[commandName] = commandDef
+	}
+
+	p.Log().Info("found provider directory")
+	return nil
+}
+
+func (p *Provider) validateConfig() error {
+	if !p.config.Declared() && p.legacyContract {
+		p.Log().Warn("provider does not declare its type and contract version, accepted for a legacy cluster")
+		return nil
+	}
+
+	// the type of a stage defaults to its name
+	if len(p.stageType) > 0 {
+		return p.config.Validate(p.stageType)
+	}
+	return p.config.Validate(p.providerType)
+}
+
 func (p *Provider) initImage(imageName string) (err error) {
-	// append latest tag if no tag
-	p.imageRepo, p.imageTag = docker.ParseRepositoryTag(imageName)
-	if len(p.imageTag) == 0 {
-		p.imageTag = "latest"
+	if strings.HasPrefix(imageName, ProviderDirScheme) {
+		return p.initDir(strings.TrimPrefix(imageName, ProviderDirScheme))
 	}
 
+	// append latest tag if no tag
+	p.imageRepo, p.imageTag, p.imageDigest = ParseImageName(imageName)
+
 	// get image
 	imageId, err := p.getImage()
 	if err != nil {
@@ -210,6 +507,10 @@ func (p *Provider) initImage(imageName string) (err error) {
 
 	p.Log().Info("found image")
 
+	if err := p.resolveDigest(); err != nil {
+		p.Log().Warn("failed to detect image digest: ", err)
+	}
+
 	err = p.readConfig()
 	if err != nil {
 		p.Log().Error(err)
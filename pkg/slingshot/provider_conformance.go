@@ -0,0 +1,284 @@
+package slingshot
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"text/tabwriter"
+
+	"github.com/simonswine/slingshot/pkg/utils"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	ConformancePass = "pass"
+	ConformanceFail = "fail"
+	ConformanceSkip = "skip"
+)
+
+// ConformanceClusterName is the name of the cluster providers see while
+// running the conformance checks
+const ConformanceClusterName = "conformance"
+
+// ConformanceCheck is the outcome of a single conformance check
+type ConformanceCheck struct {
+	Name    string `json:"name" yaml:"name"`
+	Result  string `json:"result" yaml:"result"`
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+}
+
+func (c ConformanceCheck) Passed() bool {
+	return c.Result != ConformanceFail
+}
+
+// ProviderConformance checks that a provider follows the contract slingshot
+// relies on during apply
+type ProviderConformance struct {
+	ImageName  string
+	Parameters *Parameters
+	slingshot  *Slingshot
+	checks     []ConformanceCheck
+}
+
+func NewProviderConformance(s *Slingshot, imageName string) *ProviderConformance {
+	return &ProviderConformance{
+		ImageName: imageName,
+		slingshot: s,
+	}
+}
+
+// ValidateSchema checks the discover output of a provider independent of
+// the slot it is used for
+func (c *ProviderConfig) ValidateSchema() (errs []error) {
+	knownType := false
+	for _, providerType := range ProviderTypes {
+		if c.Provider.Type == providerType {
+			knownType = true
+		}
+	}
+	if !stageNameRegexp.MatchString(c.Provider.Type) {
+		errs = append(errs, fieldError("provider.type", "provider type '%s' did not match '%s'", c.Provider.Type, stageNameRegexp))
+	} else if err := c.Validate(c.Provider.Type); err != nil {
+		errs = append(errs, fieldError("provider.version", "%s", err))
+	} else if !knownType {
+		errs = append(errs, fieldWarning("provider.type", "provider type '%s' can only be used by stages of this type, well-known are %v", c.Provider.Type, ProviderTypes))
+	}
+
+	if _, ok := c.Commands["apply"]; !ok {
+		errs = append(errs, fieldError("commands.apply", "required command missing"))
+	}
+
+	for commandName, commandDef := range c.Commands {
+		path := fmt.Sprintf("commands.%s", commandName)
+		if commandDef.Type != "host" && commandDef.Type != "docker" {
+			errs = append(errs, fieldError(path+".type", "unknown command type '%s'", commandDef.Type))
+		}
+		if len(commandDef.Execs) == 0 {
+			errs = append(errs, fieldWarning(path+".execs", "command has nothing to execute"))
+		}
+		errs = append(errs, validateEnvironment(path+".environment", commandDef.Environment)...)
+		if commandName == "apply" {
+			if commandDef.ParameterFile == nil {
+				errs = append(errs, fieldError(path+".parameterFile", "required field missing"))
+			}
+			if commandDef.ResultFile == nil {
+				errs = append(errs, fieldError(path+".resultFile", "required field missing"))
+			}
+		}
+	}
+
+	return errs
+}
+
+// conformanceParameters returns the canned parameters the provider is
+// applied with, providers of stages after infrastructure get an inventory
+func conformanceParameters(providerType string) (*Parameters, error) {
+	params := &Parameters{}
+	params.Defaults()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	privateKey := string(pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}))
+	params.General.Authentication.Ssh.PrivateKey = &privateKey
+
+	if providerType != "infrastructure" {
+		hosts := []struct {
+			name string
+			ip   string
+			role string
+		}{
+			{"master-1", "10.0.0.10", "masters"},
+			{"worker-1", "10.0.0.20", "workers"},
+			{"worker-2", "10.0.0.21", "workers"},
+		}
+		for _, host := range hosts {
+			name, ip := host.name, host.ip
+			params.Inventory = append(params.Inventory, ParameterInventory{
+				Name:      &name,
+				PublicIP:  &ip,
+				PrivateIP: &ip,
+				Roles:     []string{host.role},
+			})
+		}
+	}
+
+	return params, nil
+}
+
+func (pc *ProviderConformance) record(name string, err error) bool {
+	check := ConformanceCheck{
+		Name:   name,
+		Result: ConformancePass,
+	}
+	if err != nil {
+		check.Result = ConformanceFail
+		check.Message = err.Error()
+	}
+	pc.checks = append(pc.checks, check)
+	return err == nil
+}
+
+func (pc *ProviderConformance) skip(names ...string) []ConformanceCheck {
+	for _, name := range names {
+		pc.checks = append(pc.checks, ConformanceCheck{
+			Name:    name,
+			Result:  ConformanceSkip,
+			Message: "previous check failed",
+		})
+	}
+	return pc.checks
+}
+
+// Run executes all conformance checks in a temporary config directory, the
+// returned error is only set if the sandbox could not be set up
+func (pc *ProviderConformance) Run() ([]ConformanceCheck, error) {
+	pc.checks = []ConformanceCheck{}
+
+	sandboxDir, err := ioutil.TempDir("", "slingshot-conformance")
+	if err != nil {
+		return nil, fmt.Errorf("Error while creating sandbox directory: %s", err)
+	}
+	defer os.RemoveAll(sandboxDir)
+
+	sandbox := &Slingshot{
+		dockerClient: pc.slingshot.dockerClient,
+		configDir:    sandboxDir,
+		pullPolicy:   pc.slingshot.pullPolicy,
+	}
+	c := NewCluster(sandbox)
+	c.Name = ConformanceClusterName
+
+	p := &Provider{
+		cluster:    c,
+		pullPolicy: c.pullPolicy(),
+	}
+	p.init("conformance")
+
+	// discover
+	if !pc.record("discover", p.initImage(pc.ImageName)) {
+		return pc.skip("schema", "apply", "result", "destroy"), nil
+	}
+
+	// schema
+	failures, _ := SplitWarnings(p.config.ValidateSchema())
+	var schemaErr error
+	if len(failures) > 0 {
+		schemaErr = fmt.Errorf("%d problems, first: %s", len(failures), failures[0])
+	}
+	if !pc.record("schema", schemaErr) {
+		return pc.skip("apply", "result", "destroy"), nil
+	}
+	p.init(p.config.Provider.Type)
+
+	// apply
+	params := pc.Parameters
+	if params == nil {
+		params, err = conformanceParameters(p.providerType)
+		if err != nil {
+			return nil, fmt.Errorf("Error while generating parameters: %s", err)
+		}
+	}
+	paramsBytes, err := yaml.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("Error while writing parameters file: %s", err)
+	}
+	if err := utils.EnsureDirectory(c.configDirPath()); err != nil {
+		return nil, fmt.Errorf("Error while creating sandbox directory: %s", err)
+	}
+
+	output, err := p.RunCommand("apply", &paramsBytes)
+	if !pc.record("apply", err) {
+		return pc.skip("result", "destroy"), nil
+	}
+
+	// result
+	pc.record("result", pc.checkResult(p.providerType, paramsBytes, output))
+
+	// destroy
+	if p.HasCommand("destroy") {
+		paramsBytes, err = yaml.Marshal(params)
+		if err == nil {
+			_, err = p.RunCommand("destroy", &paramsBytes)
+		}
+		pc.record("destroy", err)
+	} else {
+		pc.checks = append(pc.checks, ConformanceCheck{
+			Name:    "destroy",
+			Result:  ConformanceSkip,
+			Message: "provider has no destroy command",
+		})
+	}
+
+	return pc.checks, nil
+}
+
+// checkResult verifies that the result of apply merged over the input
+// parameters is valid
+func (pc *ProviderConformance) checkResult(providerType string, input []byte, output []byte) error {
+	result := &Parameters{}
+	if err := result.Parse(string(input)); err != nil {
+		return err
+	}
+	if err := result.Parse(string(output)); err != nil {
+		return fmt.Errorf("result does not parse: %s", err)
+	}
+
+	failures, _ := SplitWarnings(result.Validate())
+	if len(failures) > 0 {
+		return fmt.Errorf("result is invalid, %d problems, first: %s", len(failures), failures[0])
+	}
+
+	if providerType == "infrastructure" && len(result.Inventory) == 0 {
+		return fmt.Errorf("result contains no inventory")
+	}
+
+	return nil
+}
+
+// WriteConformanceTable writes the checks in tab-separated columns
+func WriteConformanceTable(out io.Writer, checks []ConformanceCheck) error {
+	w := new(tabwriter.Writer)
+
+	// Format in tab-separated columns with a tab stop of 8.
+	w.Init(out, 0, 8, 1, '\t', 0)
+	fmt.Fprintln(w, "Check\tResult\tMessage")
+	for _, check := range checks {
+		message := check.Message
+		if len(message) == 0 {
+			message = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", check.Name, check.Result, message)
+	}
+	fmt.Fprintln(w)
+	return w.Flush()
+}
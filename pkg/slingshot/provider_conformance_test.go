@@ -0,0 +1,113 @@
+package slingshot
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+const conformanceDiscover = `provider:
+  type: infrastructure
+  version: "1"
+commands:
+  apply:
+    type: host
+    parameterFile: parameters.yaml
+    resultFile: result.yaml
+    execs:
+      - [cp, inventory.yaml, result.yaml]
+`
+
+const conformanceInventory = `inventory:
+- name: master-1
+  publicIP: 10.0.0.10
+  privateIP: 10.0.0.10
+  roles: [masters]
+`
+
+func TestProviderConfigValidateSchema(t *testing.T) {
+	config := &ProviderConfig{}
+	assert.Nil(t, config.Parse(conformanceDiscover))
+	assert.Empty(t, config.ValidateSchema())
+
+	config = &ProviderConfig{}
+	assert.Nil(t, config.Parse(`provider:
+  type: Addons
+  version: "1"
+commands:
+  apply:
+    type: vm
+`))
+	var paths []string
+	for _, valErr := range ValidationErrors(config.ValidateSchema()) {
+		paths = append(paths, valErr.Path)
+	}
+	assert.Equal(t, []string{
+		"commands.apply.parameterFile",
+		"commands.apply.resultFile",
+		"commands.apply.type",
+		"provider.type",
+		"commands.apply.execs",
+	}, paths)
+
+	// providers of custom stages are valid
+	config = &ProviderConfig{}
+	assert.Nil(t, config.Parse(strings.Replace(conformanceDiscover, "infrastructure", "addons", 1)))
+	failures, warnings := SplitWarnings(config.ValidateSchema())
+	assert.Empty(t, failures)
+	assert.Equal(t, 1, len(warnings))
+}
+
+func TestProviderConformanceRun(t *testing.T) {
+	sourceDir, err := ioutil.TempDir("", AppName)
+	assert.Nil(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(sourceDir)
+
+	err = ioutil.WriteFile(path.Join(sourceDir, ProviderDirDiscoverFile), []byte(conformanceDiscover), 0644)
+	assert.Nil(t, err, "Unexpected error writing file")
+	err = ioutil.WriteFile(path.Join(sourceDir, "inventory.yaml"), []byte(conformanceInventory), 0644)
+	assert.Nil(t, err, "Unexpected error writing file")
+
+	checks, err := NewProviderConformance(&Slingshot{}, ProviderDirScheme+sourceDir).Run()
+	assert.Nil(t, err, "Unexpected error running conformance checks")
+
+	results := map[string]string{}
+	for _, check := range checks {
+		results[check.Name] = check.Result
+	}
+	assert.Equal(t, map[string]string{
+		"discover": ConformancePass,
+		"schema":   ConformancePass,
+		"apply":    ConformancePass,
+		"result":   ConformancePass,
+		"destroy":  ConformanceSkip,
+	}, results)
+}
+
+func TestProviderConformanceCheckResult(t *testing.T) {
+	pc := &ProviderConformance{}
+	params, err := conformanceParameters("infrastructure")
+	assert.Nil(t, err)
+	input, err := yaml.Marshal(params)
+	assert.Nil(t, err)
+
+	assert.Nil(t, pc.checkResult("infrastructure", input, []byte(conformanceInventory)))
+
+	err = pc.checkResult("infrastructure", input, []byte("inventory: []\n"))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "no inventory")
+
+	err = pc.checkResult("infrastructure", input, []byte("inventory:\n- name: master-1\n"))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "result is invalid")
+
+	configParams, err := conformanceParameters("config")
+	assert.Nil(t, err)
+	failures, _ := SplitWarnings(configParams.Validate())
+	assert.Empty(t, failures)
+}
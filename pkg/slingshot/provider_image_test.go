@@ -0,0 +1,119 @@
+package slingshot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testImageId1 = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	testImageId2 = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+	testImageId3 = "sha256:3333333333333333333333333333333333333333333333333333333333333333"
+)
+
+// prepareFakeDockerProvider returns a provider connected to a fake docker
+// API that lists the given images
+func prepareFakeDockerProvider(t *testing.T, images []docker.APIImages) (*Provider, func()) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/images/json") {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(images)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+
+	client, err := docker.NewClient(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Provider{
+		cluster: &Cluster{
+			slingshot: &Slingshot{
+				dockerClient: client,
+			},
+		},
+		pullPolicy: PullPolicyNever,
+	}
+	p.init("infrastructure")
+
+	return p, server.Close
+}
+
+func TestProviderGetImageMultipleTags(t *testing.T) {
+	p, cleanUp := prepareFakeDockerProvider(t, []docker.APIImages{
+		{
+			ID:       testImageId1,
+			RepoTags: []string{"example/ip:1.0", "example/ip:latest"},
+		},
+		{
+			ID:       testImageId2,
+			RepoTags: []string{"example/ip:0.9"},
+		},
+		{
+			ID:          testImageId3,
+			RepoTags:    []string{"<none>:<none>"},
+			RepoDigests: []string{"example/ip@sha256:abcdef"},
+		},
+	})
+	defer cleanUp()
+
+	p.imageRepo, p.imageTag, p.imageDigest = ParseImageName("example/ip")
+	id, err := p.getImage()
+	assert.Nil(t, err, "Unexpected error during image resolution")
+	assert.Equal(t, testImageId1, id)
+
+	p.imageRepo, p.imageTag, p.imageDigest = ParseImageName("example/ip:0.9")
+	id, err = p.getImage()
+	assert.Nil(t, err, "Unexpected error during image resolution")
+	assert.Equal(t, testImageId2, id)
+
+	p.imageRepo, p.imageTag, p.imageDigest = ParseImageName("example/ip@sha256:abcdef")
+	id, err = p.getImage()
+	assert.Nil(t, err, "Unexpected error during image resolution")
+	assert.Equal(t, testImageId3, id)
+}
+
+func TestProviderGetImageNotFound(t *testing.T) {
+	p, cleanUp := prepareFakeDockerProvider(t, []docker.APIImages{
+		{
+			ID:       testImageId1,
+			RepoTags: []string{"example/ip:1.0"},
+		},
+	})
+	defer cleanUp()
+
+	p.imageRepo, p.imageTag, p.imageDigest = ParseImageName("example/ip:2.0")
+	_, err := p.getImage()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "not available locally")
+}
+
+func TestMatchImagesAmbiguous(t *testing.T) {
+	list := []docker.APIImages{
+		{
+			ID:          testImageId1,
+			RepoDigests: []string{"example/ip@sha256:abcdef"},
+		},
+		{
+			ID:          testImageId2,
+			RepoDigests: []string{"example/ip@sha256:abcdef"},
+		},
+		{
+			ID:          testImageId2,
+			RepoDigests: []string{"example/ip@sha256:abcdef"},
+		},
+	}
+
+	matching := matchImages(list, "example/ip@sha256:abcdef", true)
+	assert.Equal(t, 2, len(matching))
+	assert.Equal(t, testImageId1, matching[0].ID)
+	assert.Equal(t, testImageId2, matching[1].ID)
+}
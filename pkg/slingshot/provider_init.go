@@ -0,0 +1,238 @@
+package slingshot
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/template"
+)
+
+// ProviderSkeleton writes the files of a new provider, that implements the
+// latest provider contract
+type ProviderSkeleton struct {
+	Name        string
+	Type        string
+	CommandType string
+	Version     string
+}
+
+type skeletonFile struct {
+	template string
+	mode     os.FileMode
+}
+
+func NewProviderSkeleton(name string, providerType string, commandType string) *ProviderSkeleton {
+	return &ProviderSkeleton{
+		Name:        name,
+		Type:        providerType,
+		CommandType: commandType,
+		Version:     ProviderContractVersions[len(ProviderContractVersions)-1],
+	}
+}
+
+func (ps *ProviderSkeleton) Validate() error {
+	if !stageNameRegexp.MatchString(ps.Type) {
+		return fmt.Errorf("provider type '%s' did not match '%s'", ps.Type, stageNameRegexp)
+	}
+
+	if ps.CommandType != "host" && ps.CommandType != "docker" {
+		return fmt.Errorf("unknown command type '%s', supported are [host docker]", ps.CommandType)
+	}
+
+	return nil
+}
+
+func (ps *ProviderSkeleton) files() map[string]skeletonFile {
+	applyPath := "bin/apply"
+	if ps.CommandType == "host" {
+		applyPath = "host/apply"
+	}
+
+	applyTemplate := skeletonApplyInfrastructure
+	if ps.Type != "infrastructure" {
+		applyTemplate = skeletonApplyConfig
+	}
+
+	files := map[string]skeletonFile{
+		"Dockerfile":   {skeletonDockerfile, 0644},
+		"README.md":    {skeletonReadme, 0644},
+		"bin/discover": {skeletonDiscover, 0755},
+		applyPath:      {applyTemplate, 0755},
+	}
+
+	if ps.CommandType == "host" {
+		files["bin/build-working-dir-content"] = skeletonFile{skeletonBuildWorkingDirContent, 0755}
+	}
+
+	return files
+}
+
+// Write creates the skeleton in dir, existing files are never overwritten
+func (ps *ProviderSkeleton) Write(dir string) (written []string, err error) {
+	if err := ps.Validate(); err != nil {
+		return nil, err
+	}
+
+	files := ps.files()
+
+	var fileNames []string
+	for fileName := range files {
+		fileName = filepath.FromSlash(fileName)
+		if _, err := os.Stat(filepath.Join(dir, fileName)); err == nil {
+			return nil, fmt.Errorf("file '%s' already exists in '%s'", fileName, dir)
+		}
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+
+	for _, fileName := range fileNames {
+		file := files[filepath.ToSlash(fileName)]
+
+		tmpl, err := template.New(fileName).Parse(file.template)
+		if err != nil {
+			return written, err
+		}
+		buf := new(bytes.Buffer)
+		if err := tmpl.Execute(buf, ps); err != nil {
+			return written, err
+		}
+
+		filePath := filepath.Join(dir, fileName)
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			return written, err
+		}
+		if err := ioutil.WriteFile(filePath, buf.Bytes(), file.mode); err != nil {
+			return written, fmt.Errorf("Error while writing '%s': %s", filePath, err)
+		}
+		written = append(written, filePath)
+	}
+
+	return written, nil
+}
+
+const skeletonDockerfile = `FROM alpine:3.4
+
+WORKDIR /slingshot
+
+COPY bin/ /usr/local/bin/
+{{- if eq .CommandType "host"}}
+
+# host commands are shipped to slingshot as workingDirContent
+COPY host/ /slingshot/host/
+RUN build-working-dir-content /slingshot/host > /slingshot/working-dir-content.b64
+{{- end}}
+`
+
+const skeletonReadme = `# {{.Name}}
+
+Provider for the {{.Type}} stage of slingshot clusters.
+
+## Layout
+
+* ` + "`bin/discover`" + ` prints the provider config, slingshot runs it inside the image
+{{- if eq .CommandType "host"}}
+* ` + "`host/apply`" + ` runs on the host of the slingshot user in a temporary directory
+* ` + "`bin/build-working-dir-content`" + ` packs ` + "`host/`" + ` as workingDirContent
+{{- else}}
+* ` + "`bin/apply`" + ` runs inside the provider container
+{{- end}}
+
+The parameters of the cluster are written to ` + "`parameters.yaml`" + ` before
+apply runs, the result is read from ` + "`result.yaml`" + `.
+
+## Development
+
+` + "```" + `
+docker build -t {{.Name}} .
+slingshot provider inspect {{.Name}}
+slingshot provider test {{.Name}}
+` + "```" + `
+`
+
+const skeletonDiscover = `#!/bin/sh
+# discover prints the provider config, slingshot runs it inside the image
+set -e
+
+cat <<EOF
+provider:
+  type: {{.Type}}
+  version: "{{.Version}}"
+commands:
+  apply:
+    type: {{.CommandType}}
+    execs:
+{{- if eq .CommandType "host"}}
+      - [./apply]
+{{- else}}
+      - [apply]
+{{- end}}
+    parameterFile: parameters.yaml
+    resultFile: result.yaml
+{{- if eq .CommandType "host"}}
+    workingDirContent: !!binary |
+EOF
+
+sed 's/^/      /' "${WORKING_DIR_CONTENT:-/slingshot/working-dir-content.b64}"
+{{- else}}
+EOF
+{{- end}}
+`
+
+const skeletonApplyInfrastructure = `#!/bin/sh
+# apply creates the machines described in parameters.yaml and writes them
+# as inventory to result.yaml
+set -e
+
+if [ ! -f parameters.yaml ]; then
+  echo "parameter file parameters.yaml not found" >&2
+  exit 1
+fi
+
+user=$(sed -n 's/^ *user: *//p' parameters.yaml | head -n 1)
+echo "machines will be accessed as user '${user}'" >&2
+
+# TODO: create the machines of general.cluster.machines and list them below
+cat > result.yaml <<EOF
+inventory:
+- name: master-1
+  publicIP: 10.0.0.10
+  privateIP: 10.0.0.10
+  roles:
+  - masters
+EOF
+`
+
+const skeletonApplyConfig = `#!/bin/sh
+# apply configures the hosts of the inventory in parameters.yaml
+set -e
+
+if [ ! -f parameters.yaml ]; then
+  echo "parameter file parameters.yaml not found" >&2
+  exit 1
+fi
+
+hosts=$(grep -c 'privateIP:' parameters.yaml || true)
+echo "configuring ${hosts} hosts" >&2
+
+# TODO: install kubernetes on the hosts and return the admin kubeconfig
+cat > result.yaml <<EOF
+kubeconfig: ""
+EOF
+`
+
+const skeletonBuildWorkingDirContent = `#!/bin/sh
+# build-working-dir-content prints the directory given as gzipped tar in
+# base64, as expected in the workingDirContent of host commands
+set -e
+
+if [ $# -ne 1 ] || [ ! -d "$1" ]; then
+  echo "usage: $0 <dir>" >&2
+  exit 1
+fi
+
+cd "$1"
+tar -czf - $(ls -A) | base64
+`
@@ -0,0 +1,103 @@
+package slingshot
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"testing"
+
+	"github.com/simonswine/slingshot/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func writeProviderSkeleton(t *testing.T, providerType string, commandType string) string {
+	dir, err := ioutil.TempDir("", AppName)
+	assert.Nil(t, err, "Unexpected error creating temp dir")
+
+	_, err = NewProviderSkeleton("test-provider", providerType, commandType).Write(dir)
+	assert.Nil(t, err, "Unexpected error writing skeleton")
+
+	return dir
+}
+
+func runSkeletonScript(t *testing.T, workDir string, env []string, script string, args ...string) []byte {
+	cmd := exec.Command(script, args...)
+	cmd.Dir = workDir
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	assert.Nil(t, err, "Unexpected error running %s", script)
+	return output
+}
+
+func TestProviderSkeletonValidate(t *testing.T) {
+	assert.Nil(t, NewProviderSkeleton("p", "config", "host").Validate())
+	assert.Nil(t, NewProviderSkeleton("p", "addons", "docker").Validate())
+	assert.NotNil(t, NewProviderSkeleton("p", "Addons", "host").Validate())
+	assert.NotNil(t, NewProviderSkeleton("p", "config", "vm").Validate())
+}
+
+func TestProviderSkeletonNoOverwrite(t *testing.T) {
+	dir := writeProviderSkeleton(t, "infrastructure", "docker")
+	defer os.RemoveAll(dir)
+
+	_, err := NewProviderSkeleton("test-provider", "infrastructure", "docker").Write(dir)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "already exists")
+}
+
+func TestProviderSkeletonDocker(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	dir := writeProviderSkeleton(t, "infrastructure", "docker")
+	defer os.RemoveAll(dir)
+
+	config := &ProviderConfig{}
+	assert.Nil(t, config.Parse(string(runSkeletonScript(t, dir, nil, path.Join(dir, "bin", "discover")))))
+	assert.Empty(t, config.ValidateSchema())
+	assert.Equal(t, []ExecConfig{{Argv: []string{"apply"}}}, config.Commands["apply"].Execs)
+
+	params, err := conformanceParameters("infrastructure")
+	assert.Nil(t, err)
+	paramsBytes, err := yaml.Marshal(params)
+	assert.Nil(t, err)
+	assert.Nil(t, ioutil.WriteFile(path.Join(dir, "parameters.yaml"), paramsBytes, 0644))
+
+	runSkeletonScript(t, dir, nil, path.Join(dir, "bin", "apply"))
+	result, err := ioutil.ReadFile(path.Join(dir, "result.yaml"))
+	assert.Nil(t, err, "Unexpected error reading result")
+	assert.Nil(t, (&ProviderConformance{}).checkResult("infrastructure", paramsBytes, result))
+}
+
+func TestProviderSkeletonHost(t *testing.T) {
+	for _, tool := range []string{"sh", "tar", "base64"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not available", tool)
+		}
+	}
+
+	dir := writeProviderSkeleton(t, "config", "host")
+	defer os.RemoveAll(dir)
+
+	content := runSkeletonScript(t, dir, nil, path.Join(dir, "bin", "build-working-dir-content"), path.Join(dir, "host"))
+	contentPath := path.Join(dir, "working-dir-content.b64")
+	assert.Nil(t, ioutil.WriteFile(contentPath, content, 0644))
+
+	config := &ProviderConfig{}
+	discover := runSkeletonScript(t, dir, []string{"WORKING_DIR_CONTENT=" + contentPath}, path.Join(dir, "bin", "discover"))
+	assert.Nil(t, config.Parse(string(discover)))
+	assert.Empty(t, config.ValidateSchema())
+	assert.Equal(t, "config", config.Provider.Type)
+
+	headers, err := utils.ListTarGz([]byte(config.Commands["apply"].WorkingDirContent))
+	assert.Nil(t, err, "Unexpected error listing workingDirContent")
+	var names []string
+	for _, header := range headers {
+		names = append(names, path.Clean(header.Name))
+	}
+	assert.Contains(t, names, "apply")
+}
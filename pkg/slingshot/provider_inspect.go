@@ -0,0 +1,129 @@
+package slingshot
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/simonswine/slingshot/pkg/utils"
+)
+
+// NewStandaloneProvider initialises a provider that is not part of a cluster
+func NewStandaloneProvider(s *Slingshot, imageName string) (*Provider, error) {
+	c := NewCluster(s)
+
+	p := &Provider{
+		cluster:    c,
+		pullPolicy: c.pullPolicy(),
+	}
+	p.init("standalone")
+
+	return p, p.initImage(imageName)
+}
+
+// WriteInspect writes the discover metadata of the provider to out
+func (p *Provider) WriteInspect(out io.Writer) error {
+	w := new(tabwriter.Writer)
+
+	// Format in tab-separated columns with a tab stop of 8.
+	w.Init(out, 0, 8, 1, '\t', 0)
+
+	valueOrNone := func(value *string) string {
+		if value == nil || len(*value) == 0 {
+			return "-"
+		}
+		return *value
+	}
+
+	fmt.Fprintf(w, "Provider:\t%s\n", p.ImageName())
+	fmt.Fprintf(w, "Type:\t%s\n", valueOrNone(&p.config.Provider.Type))
+	fmt.Fprintf(w, "Version:\t%s\n", valueOrNone(&p.config.Provider.Version))
+
+	var commandNames []string
+	for commandName := range p.config.Commands {
+		commandNames = append(commandNames, commandName)
+	}
+	sort.Strings(commandNames)
+
+	for _, commandName := range commandNames {
+		commandDef := p.config.Commands[commandName]
+
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "Command:\t%s\n", commandName)
+		fmt.Fprintf(w, "  Type:\t%s\n", commandDef.Type)
+		fmt.Fprintf(w, "  Parameter File:\t%s\n", valueOrNone(commandDef.ParameterFile))
+		fmt.Fprintf(w, "  Result File:\t%s\n", valueOrNone(commandDef.ResultFile))
+
+		persistPaths := "-"
+		if len(commandDef.PersistPaths) > 0 {
+			persistPaths = strings.Join(commandDef.PersistPaths, ", ")
+		}
+		fmt.Fprintf(w, "  Persist Paths:\t%s\n", persistPaths)
+
+		if len(commandDef.Environment) > 0 {
+			fmt.Fprintf(w, "  Environment:\t\n")
+			for _, variable := range commandDef.Environment {
+				var options []string
+				if variable.Secret {
+					options = append(options, "secret")
+				}
+				if variable.Optional {
+					options = append(options, "optional")
+				}
+				fmt.Fprintf(w, "    - %s\t%s\t%s\n", variable.Name, strings.Join(options, " "), variable.Description)
+			}
+		}
+
+		fmt.Fprintf(w, "  Execs:\t\n")
+		for _, execSingle := range commandDef.Execs {
+			var options []string
+			if execSingle.AllowFailure {
+				options = append(options, "allowFailure")
+			}
+			if execSingle.Retries > 0 {
+				options = append(options, fmt.Sprintf("retries=%d", execSingle.Retries))
+			}
+			if len(execSingle.Timeout) > 0 {
+				options = append(options, fmt.Sprintf("timeout=%s", execSingle.Timeout))
+			}
+			if len(execSingle.WorkDir) > 0 {
+				options = append(options, fmt.Sprintf("workDir=%s", execSingle.WorkDir))
+			}
+			if len(execSingle.Env) > 0 {
+				var envKeys []string
+				for key := range execSingle.Env {
+					envKeys = append(envKeys, key)
+				}
+				sort.Strings(envKeys)
+				options = append(options, fmt.Sprintf("env=%s", strings.Join(envKeys, ",")))
+			}
+			if len(execSingle.When) > 0 {
+				options = append(options, fmt.Sprintf("when='%s'", execSingle.When))
+			}
+			fmt.Fprintf(w, "    - %s\t%s\n", execSingle.String(), strings.Join(options, " "))
+		}
+
+		if len(commandDef.WorkingDirContent) > 0 {
+			headers, err := utils.ListTarGz([]byte(commandDef.WorkingDirContent))
+			if err != nil {
+				fmt.Fprintf(w, "  Working Dir Content:\tunreadable: %s\n", err)
+				continue
+			}
+			fmt.Fprintf(w, "  Working Dir Content:\t\n")
+			for _, header := range headers {
+				fmt.Fprintf(
+					w,
+					"    %s\t%d\t%s\n",
+					header.FileInfo().Mode().String(),
+					header.Size,
+					header.Name,
+				)
+			}
+		}
+	}
+
+	fmt.Fprintln(w)
+	return w.Flush()
+}
@@ -13,7 +13,8 @@ import (
 )
 
 type MockProvider struct {
-	tmpDir *string
+	tmpDir      *string
+	interrupted chan struct{}
 }
 
 func (p *MockProvider) StatePath() string {
@@ -38,6 +39,10 @@ func (p *MockProvider) Docker() *docker.Client {
 	return dockerClient
 }
 
+func (p *MockProvider) Interrupted() <-chan struct{} {
+	return p.interrupted
+}
+
 func (p *MockProvider) DockerImageId() *string {
 	str := "busybox:latest"
 	return &str
@@ -75,7 +80,7 @@ commands:
 	assert.Equal(t, "infrastructure", c.Provider.Type)
 	assert.Equal(t, "1", c.Provider.Version)
 
-	assert.Equal(t, [][]string{[]string{"vagrant", "up"}}, c.Commands["apply"].Execs)
+	assert.Equal(t, []ExecConfig{{Argv: []string{"vagrant", "up"}}}, c.Commands["apply"].Execs)
 	assert.Equal(t, "host", c.Commands["apply"].Type)
 	assert.Equal(t, "params.yaml", *c.Commands["apply"].ParameterFile)
 	assert.Equal(t, "output.yaml", *c.Commands["apply"].ResultFile)
@@ -86,3 +91,56 @@ commands:
 		fmt.Sprintf("%x", md5.Sum([]byte(c.Commands["apply"].WorkingDirContent))),
 	)
 }
+
+func TestParseImageName(t *testing.T) {
+	repo, tag, digest := ParseImageName("simonswine/slingshot-ip-vagrant-coreos")
+	assert.Equal(t, "simonswine/slingshot-ip-vagrant-coreos", repo)
+	assert.Equal(t, "latest", tag)
+	assert.Equal(t, "", digest)
+
+	repo, tag, digest = ParseImageName("localhost:5000/ip:1.0")
+	assert.Equal(t, "localhost:5000/ip", repo)
+	assert.Equal(t, "1.0", tag)
+	assert.Equal(t, "", digest)
+
+	repo, tag, digest = ParseImageName("localhost:5000/ip@sha256:0123abc")
+	assert.Equal(t, "localhost:5000/ip", repo)
+	assert.Equal(t, "", tag)
+	assert.Equal(t, "sha256:0123abc", digest)
+}
+
+func TestProviderConfigValidate(t *testing.T) {
+	c := &ProviderConfig{}
+	err := c.Parse(`provider:
+  type: infrastructure
+  version: 1`)
+	assert.Nil(t, err, "Unexpected error during parsing")
+
+	assert.Nil(t, c.Validate("infrastructure"))
+
+	err = c.Validate("config")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "declares type 'infrastructure' but is used as config provider")
+
+	c.Provider.Version = "2"
+	err = c.Validate("infrastructure")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "contract version '2'")
+
+	c = &ProviderConfig{}
+	assert.False(t, c.Declared())
+	assert.NotNil(t, c.Validate("infrastructure"))
+}
+
+func TestClusterCheckVersion(t *testing.T) {
+	c := NewCluster(&Slingshot{})
+	assert.Nil(t, c.checkVersion())
+	assert.False(t, c.legacyProviders())
+
+	c.Version = ClusterVersionLegacy
+	assert.Nil(t, c.checkVersion())
+	assert.True(t, c.legacyProviders())
+
+	c.Version = "99"
+	assert.NotNil(t, c.checkVersion())
+}
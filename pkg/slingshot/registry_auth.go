@@ -0,0 +1,238 @@
+package slingshot
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/simonswine/slingshot/pkg/utils"
+)
+
+const DockerHubRegistry = "https://index.docker.io/v1/"
+
+const (
+	PullErrorAuth     = "auth"
+	PullErrorNotFound = "not-found"
+	PullErrorOther    = "other"
+)
+
+// DockerConfigFile is the subset of ~/.docker/config.json used for
+// registry authentication
+type DockerConfigFile struct {
+	Auths map[string]struct {
+		Auth  string `json:"auth"`
+		Email string `json:"email"`
+	} `json:"auths"`
+	CredsStore  string            `json:"credsStore"`
+	CredHelpers map[string]string `json:"credHelpers"`
+}
+
+func dockerConfigFilePath() (string, error) {
+	if dir := os.Getenv("DOCKER_CONFIG"); len(dir) > 0 {
+		return path.Join(dir, "config.json"), nil
+	}
+	homeDir, err := utils.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(homeDir, ".docker", "config.json"), nil
+}
+
+// LoadDockerConfigFile reads the docker config of the user, a missing file
+// results in an empty config
+func LoadDockerConfigFile() (*DockerConfigFile, error) {
+	d := &DockerConfigFile{}
+
+	filePath, err := dockerConfigFilePath()
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := ioutil.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return d, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := d.Parse(content); err != nil {
+		return nil, fmt.Errorf("Error while parsing docker config '%s': %s", filePath, err)
+	}
+	return d, nil
+}
+
+func (d *DockerConfigFile) Parse(content []byte) error {
+	return json.Unmarshal(content, d)
+}
+
+// AuthConfiguration returns the credentials for registry, either from a
+// credential helper or the auths section. An empty configuration is returned
+// if there are no credentials for the registry.
+func (d *DockerConfigFile) AuthConfiguration(registry string) (docker.AuthConfiguration, error) {
+	helper := d.CredsStore
+	if h, ok := d.CredHelpers[registryHostname(registry)]; ok {
+		helper = h
+	}
+
+	if len(helper) > 0 {
+		auth, found, err := credentialHelperGet(helper, registry)
+		if err != nil {
+			return docker.AuthConfiguration{}, err
+		}
+		if found {
+			return auth, nil
+		}
+	}
+
+	for key, entry := range d.Auths {
+		if registryHostname(key) != registryHostname(registry) {
+			continue
+		}
+
+		data, err := base64.StdEncoding.DecodeString(entry.Auth)
+		if err != nil {
+			return docker.AuthConfiguration{}, fmt.Errorf("Error while decoding credentials for registry '%s': %s", key, err)
+		}
+		userpass := strings.SplitN(string(data), ":", 2)
+		if len(userpass) != 2 {
+			return docker.AuthConfiguration{}, fmt.Errorf("invalid credentials for registry '%s'", key)
+		}
+
+		return docker.AuthConfiguration{
+			Username:      userpass[0],
+			Password:      userpass[1],
+			Email:         entry.Email,
+			ServerAddress: key,
+		}, nil
+	}
+
+	return docker.AuthConfiguration{}, nil
+}
+
+// credentialHelperGet queries a docker credential helper for the registry
+func credentialHelperGet(helper string, registry string) (auth docker.AuthConfiguration, found bool, err error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command(fmt.Sprintf("docker-credential-%s", helper), "get")
+	cmd.Stdin = strings.NewReader(registry)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		output := strings.TrimSpace(stdout.String() + stderr.String())
+		if strings.Contains(output, "credentials not found") {
+			return auth, false, nil
+		}
+		return auth, false, fmt.Errorf("credential helper '%s' failed for registry '%s': %s %s", helper, registry, err, output)
+	}
+
+	result := struct {
+		ServerURL string
+		Username  string
+		Secret    string
+	}{}
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+		return auth, false, fmt.Errorf("cannot parse output of credential helper '%s': %s", helper, err)
+	}
+
+	return docker.AuthConfiguration{
+		Username:      result.Username,
+		Password:      result.Secret,
+		ServerAddress: registry,
+	}, true, nil
+}
+
+// RegistryFromRepository returns the registry of a repository name, images
+// without registry are on the docker hub
+func RegistryFromRepository(repository string) string {
+	parts := strings.SplitN(repository, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		return parts[0]
+	}
+	return DockerHubRegistry
+}
+
+// ParseRegistryAuth parses a registry credential mapping given as
+// registry=auth-registry
+func ParseRegistryAuth(value string) (registry string, authRegistry string, err error) {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return "", "", fmt.Errorf("registry auth '%s' is not in the format registry=auth-registry", value)
+	}
+	return registryHostname(parts[0]), parts[1], nil
+}
+
+// registryAuth returns the registry whose credentials are used to pull
+// images from registry
+func (c *Cluster) registryAuth(registry string) string {
+	if authRegistry, ok := c.RegistryAuth[registryHostname(registry)]; ok {
+		return authRegistry
+	}
+	return registry
+}
+
+// registryHostname normalises registry addresses like they appear in the
+// docker config
+func registryHostname(registry string) string {
+	registry = strings.TrimPrefix(registry, "https://")
+	registry = strings.TrimPrefix(registry, "http://")
+	registry = strings.SplitN(registry, "/", 2)[0]
+	if registry == "docker.io" || registry == "registry-1.docker.io" {
+		registry = "index.docker.io"
+	}
+	return registry
+}
+
+// ImagePullError tells apart authentication failures from missing images
+type ImagePullError struct {
+	Image    string
+	Registry string
+	Reason   string
+	Err      error
+}
+
+func (e *ImagePullError) Error() string {
+	switch e.Reason {
+	case PullErrorAuth:
+		return fmt.Sprintf(
+			"authentication to registry '%s' failed while pulling image '%s', check the credentials in your docker config or --registry-auth: %s",
+			e.Registry,
+			e.Image,
+			e.Err,
+		)
+	case PullErrorNotFound:
+		return fmt.Sprintf("image '%s' not found in registry '%s': %s", e.Image, e.Registry, e.Err)
+	}
+	return fmt.Sprintf("pulling image '%s' from registry '%s' failed: %s", e.Image, e.Registry, e.Err)
+}
+
+func newImagePullError(image string, registry string, err error) *ImagePullError {
+	e := &ImagePullError{
+		Image:    image,
+		Registry: registry,
+		Reason:   PullErrorOther,
+		Err:      err,
+	}
+
+	message := strings.ToLower(err.Error())
+	if dErr, ok := err.(*docker.Error); ok && dErr.Status == 401 {
+		e.Reason = PullErrorAuth
+	} else if dErr, ok := err.(*docker.Error); ok && dErr.Status == 404 {
+		e.Reason = PullErrorNotFound
+	} else if err == docker.ErrNoSuchImage {
+		e.Reason = PullErrorNotFound
+	} else if strings.Contains(message, "unauthorized") || strings.Contains(message, "authentication required") || strings.Contains(message, "access denied") {
+		e.Reason = PullErrorAuth
+	} else if strings.Contains(message, "not found") || strings.Contains(message, "does not exist") {
+		e.Reason = PullErrorNotFound
+	}
+
+	return e
+}
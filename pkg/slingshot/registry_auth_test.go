@@ -0,0 +1,74 @@
+package slingshot
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryFromRepository(t *testing.T) {
+	assert.Equal(t, DockerHubRegistry, RegistryFromRepository("busybox"))
+	assert.Equal(t, DockerHubRegistry, RegistryFromRepository("simonswine/slingshot-ip-vagrant-coreos"))
+	assert.Equal(t, "registry.example.com", RegistryFromRepository("registry.example.com/slingshot/ip"))
+	assert.Equal(t, "localhost:5000", RegistryFromRepository("localhost:5000/ip"))
+	assert.Equal(t, "localhost", RegistryFromRepository("localhost/ip"))
+}
+
+func TestDockerConfigFileAuthConfiguration(t *testing.T) {
+	d := &DockerConfigFile{}
+	err := d.Parse([]byte(`{
+  "auths": {
+    "https://index.docker.io/v1/": {
+      "auth": "dXNlcjE6cGFzczE="
+    },
+    "registry.example.com": {
+      "auth": "dXNlcjI6cGFzczI=",
+      "email": "user2@example.com"
+    }
+  },
+  "credHelpers": {
+    "gcr.io": "gcr"
+  }
+}`))
+	assert.Nil(t, err, "Unexpected error during parsing")
+
+	auth, err := d.AuthConfiguration(DockerHubRegistry)
+	assert.Nil(t, err)
+	assert.Equal(t, "user1", auth.Username)
+	assert.Equal(t, "pass1", auth.Password)
+
+	auth, err = d.AuthConfiguration("https://registry.example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, "user2", auth.Username)
+	assert.Equal(t, "user2@example.com", auth.Email)
+
+	auth, err = d.AuthConfiguration("unknown.example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, "", auth.Username)
+}
+
+func TestImagePullErrorReason(t *testing.T) {
+	err := newImagePullError("example/ip:latest", DockerHubRegistry, errors.New("unauthorized: authentication required"))
+	assert.Equal(t, PullErrorAuth, err.Reason)
+
+	err = newImagePullError("example/ip:latest", DockerHubRegistry, errors.New("Tag latest not found in repository docker.io/example/ip"))
+	assert.Equal(t, PullErrorNotFound, err.Reason)
+
+	err = newImagePullError("example/ip:latest", DockerHubRegistry, errors.New("connection refused"))
+	assert.Equal(t, PullErrorOther, err.Reason)
+}
+
+func TestClusterRegistryAuth(t *testing.T) {
+	registry, authRegistry, err := ParseRegistryAuth("docker.io=mirror.example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, "index.docker.io", registry)
+	assert.Equal(t, "mirror.example.com", authRegistry)
+
+	_, _, err = ParseRegistryAuth("mirror.example.com")
+	assert.NotNil(t, err)
+
+	c := &Cluster{RegistryAuth: map[string]string{registry: authRegistry}}
+	assert.Equal(t, "mirror.example.com", c.registryAuth(RegistryFromRepository("simonswine/slingshot-ip-vagrant-coreos")))
+	assert.Equal(t, "registry.example.com", c.registryAuth(RegistryFromRepository("registry.example.com/slingshot/ip")))
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"fmt"
 	log "github.com/Sirupsen/logrus"
@@ -21,18 +22,39 @@ type Slingshot struct {
 	dockerClient *docker.Client
 	clusters     []*Cluster
 	configDir    string
+	pullPolicy   string
+
+	interrupted   chan struct{}
+	interruptOnce sync.Once
 }
 
 func NewSlingshot() *Slingshot {
 	log.SetLevel(log.DebugLevel)
 
-	s := &Slingshot{}
+	s := &Slingshot{
+		interrupted: make(chan struct{}),
+	}
 
 	s.App = cli.NewApp()
 	s.App.Name = AppName
 	s.App.Version = AppVersion
 	s.App.Usage = "yet another zero to kubernetes utility"
 	s.App.Commands = s.Commands()
+	s.App.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "pull-policy",
+			Usage: "Pull policy for provider images for this invocation (always, if-not-present, never)",
+		},
+	}
+	s.App.Before = func(context *cli.Context) error {
+		if context.IsSet("pull-policy") {
+			if err := ValidatePullPolicy(context.String("pull-policy")); err != nil {
+				return err
+			}
+			s.pullPolicy = context.String("pull-policy")
+		}
+		return nil
+	}
 
 	return s
 }
@@ -41,6 +63,7 @@ func (s *Slingshot) Init() {
 	s.log().Infof("initialise %s %s (%s)", AppName, AppVersion, GitCommit)
 	s.ensureConfigDir()
 	s.loadClusters()
+	s.handleInterrupts()
 }
 
 func (s *Slingshot) loadClusters() {
@@ -63,6 +86,9 @@ func (s *Slingshot) loadClusters() {
 			c, err := LoadClusterFromPath(s, configPath)
 			if err != nil {
 				s.log().Warnf("Could not read cluster in '%s': %s", configPath, err)
+				c = NewCluster(s)
+				c.Name = f.Name()
+				c.loadErr = err
 			}
 
 			s.clusters = append(s.clusters, c)
@@ -74,6 +100,15 @@ func (s *Slingshot) loadClusters() {
 func (s *Slingshot) getClusterByName(name string) (*Cluster, error) {
 	for _, cluster := range s.clusters {
 		if cluster.Name == name {
+			if cluster.loadErr != nil {
+				return cluster, fmt.Errorf(
+					"cluster '%s' could not be loaded: %s, please fix its config or remove '%s' with 'cluster destroy --force %s'",
+					name,
+					cluster.loadErr,
+					cluster.configDirPath(),
+					name,
+				)
+			}
 			return cluster, nil
 		}
 	}
@@ -126,9 +161,7 @@ func (s *Slingshot) clusterCreateAction(context *cli.Context) {
 
 	errs := c.Create(context)
 	if len(errs) > 0 {
-		for _, err := range errs {
-			s.log().Error(err)
-		}
+		s.logErrors(errs)
 		s.log().Fatalf("errors prevent execution of '%s'", context.Command.HelpName)
 	}
 }
@@ -154,11 +187,183 @@ func (s *Slingshot) clusterApplyAction(context *cli.Context) {
 		s.log().Fatal(err)
 	}
 
+	if context.Bool("plan") {
+		diff, errs := c.Plan(context)
+		fmt.Print(diff)
+		if len(errs) == 0 {
+			if len(diff) == 0 {
+				fmt.Println("no changes planned")
+			}
+			return
+		}
+		s.logErrors(errs)
+		s.log().Fatalf("errors prevent execution of '%s'", context.Command.HelpName)
+	}
+
 	errs := c.Apply(context)
 	if len(errs) > 0 {
-		for _, err := range errs {
-			log.Error(err)
+		s.logErrors(errs)
+		s.log().Fatalf("errors prevent execution of '%s'", context.Command.HelpName)
+	}
+}
+
+func (s *Slingshot) clusterDestroyAction(context *cli.Context) {
+	s.Init()
+
+	cName, err := s.readClusterName(context)
+	if err != nil {
+		s.log().Fatal(err)
+	}
+
+	// clusters whose config can not be loaded can be removed with --force
+	c, err := s.getClusterByName(cName)
+	if err != nil && (c == nil || !context.Bool("force")) {
+		s.log().Fatal(err)
+	}
+
+	errs := c.Destroy(context)
+	if len(errs) > 0 {
+		s.logErrors(errs)
+		s.log().Fatalf("errors prevent execution of '%s'", context.Command.HelpName)
+	}
+}
+
+func (s *Slingshot) clusterStatusAction(context *cli.Context) {
+	s.Init()
+
+	cName, err := s.readClusterName(context)
+	if err != nil {
+		s.log().Fatal(err)
+	}
+
+	c, err := s.getClusterByName(cName)
+	if err != nil {
+		s.log().Fatal(err)
+	}
+
+	status, errs := c.Status(context)
+	if status != nil {
+		switch output := context.String("output"); output {
+		case "yaml":
+			err = status.WriteYAML(os.Stdout)
+		case "table", "":
+			err = status.WriteTable(os.Stdout)
+		default:
+			err = fmt.Errorf("unknown output format '%s'", output)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		s.logErrors(errs)
+		s.log().Fatalf("errors prevent execution of '%s'", context.Command.HelpName)
+	}
+}
+
+func (s *Slingshot) clusterKubeconfigAction(context *cli.Context) {
+	s.Init()
+
+	cName, err := s.readClusterName(context)
+	if err != nil {
+		s.log().Fatal(err)
+	}
+
+	c, err := s.getClusterByName(cName)
+	if err != nil {
+		s.log().Fatal(err)
+	}
+
+	kubeconfig, errs := c.Kubeconfig(context)
+
+	if len(errs) == 0 && context.Bool("merge") {
+		kubeconfigPath, err := utils.KubeConfigPath()
+		if err == nil {
+			err = c.MergeKubeconfig(kubeconfig, kubeconfigPath)
 		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) == 0 && context.Bool("print") {
+		os.Stdout.Write(kubeconfig.Content())
+	}
+
+	if len(errs) > 0 {
+		s.logErrors(errs)
+		s.log().Fatalf("errors prevent execution of '%s'", context.Command.HelpName)
+	}
+}
+
+func (s *Slingshot) clusterSshAction(context *cli.Context) {
+	s.Init()
+
+	cName, err := s.readClusterName(context)
+	if err != nil {
+		s.log().Fatal(err)
+	}
+
+	c, err := s.getClusterByName(cName)
+	if err != nil {
+		s.log().Fatal(err)
+	}
+
+	args := context.Args().Tail()
+	target := ""
+	if len(args) > 0 {
+		target = args[0]
+		args = args[1:]
+	}
+	command := strings.Join(args, " ")
+
+	hosts, err := c.sshHosts(target)
+	if err != nil {
+		s.log().Fatal(err)
+	}
+	c.sshInsecureIgnoreHostKey = context.Bool("insecure-ignore-host-key")
+
+	var errs []error
+	if context.Bool("all") {
+		if len(command) == 0 {
+			s.log().Fatal("please provide a command to run on all hosts")
+		}
+		errs = c.SshRun(hosts, command)
+	} else if err := c.SshInteractive(hosts[0], command); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		s.logErrors(errs)
+		s.log().Fatalf("errors prevent execution of '%s'", context.Command.HelpName)
+	}
+}
+
+func (s *Slingshot) clusterEditAction(context *cli.Context) {
+	s.Init()
+
+	cName, err := s.readClusterName(context)
+	if err != nil {
+		s.log().Fatal(err)
+	}
+
+	c, err := s.getClusterByName(cName)
+	if err != nil {
+		s.log().Fatal(err)
+	}
+
+	changed, errs := c.Edit()
+	if len(errs) == 0 && changed {
+		if context.Bool("apply") || confirm("parameters saved, apply changes now?") {
+			errs = c.Apply(context)
+		}
+	} else if len(errs) == 0 {
+		s.log().Info("parameters not changed")
+	}
+
+	if len(errs) > 0 {
+		s.logErrors(errs)
 		s.log().Fatalf("errors prevent execution of '%s'", context.Command.HelpName)
 	}
 }
@@ -166,29 +371,195 @@ func (s *Slingshot) clusterApplyAction(context *cli.Context) {
 func (s *Slingshot) clusterListAction(context *cli.Context) {
 	s.Init()
 
+	var entries []ClusterListEntry
+	for _, cluster := range s.clusters {
+		entries = append(entries, cluster.ListEntry())
+	}
+
+	var err error
+	switch output := context.String("output"); output {
+	case "json":
+		err = WriteClusterListJSON(os.Stdout, entries)
+	case "yaml":
+		err = WriteClusterListYAML(os.Stdout, entries)
+	case "table", "":
+		err = WriteClusterListTable(os.Stdout, entries)
+	default:
+		err = fmt.Errorf("unknown output format '%s'", output)
+	}
+	if err != nil {
+		s.log().Fatal(err)
+	}
+}
+
+func (s *Slingshot) clusterUpgradeProvidersAction(context *cli.Context) {
+	s.Init()
+
+	cName, err := s.readClusterName(context)
+	if err != nil {
+		s.log().Fatal(err)
+	}
+
+	c, err := s.getClusterByName(cName)
+	if err != nil {
+		s.log().Fatal(err)
+	}
+
+	upgrades, errs := c.UpgradeProviders(context)
+	if len(errs) > 0 {
+		s.logErrors(errs)
+		s.log().Fatalf("errors prevent execution of '%s'", context.Command.HelpName)
+	}
+
 	w := new(tabwriter.Writer)
 
 	// Format in tab-separated columns with a tab stop of 8.
-	w.Init(os.Stdout, 0, 8, 0, '\t', 0)
-	fmt.Fprintln(w, "Cluster Name\tInfrastructure Provider\tConfig Provider")
-
-	for _, cluster := range s.clusters {
-		infra := cluster.ProviderImageNames["infrastructure"]
-		config := cluster.ProviderImageNames["config"]
-		if config == nil || infra == nil {
-			continue
+	w.Init(os.Stdout, 0, 8, 1, '\t', 0)
+	fmt.Fprintln(w, "Provider\tImage\tOld Digest\tNew Digest")
+	for _, upgrade := range upgrades {
+		oldDigest := upgrade.OldDigest
+		if len(oldDigest) == 0 {
+			oldDigest = "-"
+		} else if oldDigest == upgrade.NewDigest {
+			oldDigest = "unchanged"
 		}
-		fmt.Fprintln(w, fmt.Sprintf(
-			"%s\t%s\t%s",
-			cluster.Name,
-			*infra,
-			*config,
-		))
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", upgrade.Provider, upgrade.ImageName, oldDigest, upgrade.NewDigest)
 	}
-
 	fmt.Fprintln(w)
 	w.Flush()
+}
+
+func (s *Slingshot) providerInspectAction(context *cli.Context) {
+	s.Init()
+
+	if context.NArg() < 1 {
+		s.log().Fatal("please provide a provider image name")
+	}
+
+	p, err := NewStandaloneProvider(s, context.Args().First())
+	if err != nil {
+		s.log().Fatal(err)
+	}
+
+	if err := p.WriteInspect(os.Stdout); err != nil {
+		s.log().Fatal(err)
+	}
+}
+
+func (s *Slingshot) providerTestAction(context *cli.Context) {
+	s.Init()
+
+	if context.NArg() < 1 {
+		s.log().Fatal("please provide a provider image name")
+	}
+
+	conformance := NewProviderConformance(s, context.Args().First())
+
+	for _, paramsPath := range context.StringSlice("parameters") {
+		if conformance.Parameters == nil {
+			conformance.Parameters = &Parameters{}
+			conformance.Parameters.Defaults()
+		}
+		paramsContent, err := ioutil.ReadFile(paramsPath)
+		if err != nil {
+			s.log().Fatalf("Error while reading parameters from '%s': %s", paramsPath, err)
+		}
+		if errs := conformance.Parameters.Merge(string(paramsContent)); len(errs) > 0 {
+			s.logErrors(errs)
+			s.log().Fatalf("errors prevent execution of '%s'", context.Command.HelpName)
+		}
+	}
+
+	checks, err := conformance.Run()
+	if err != nil {
+		s.log().Fatal(err)
+	}
+
+	if err := WriteConformanceTable(os.Stdout, checks); err != nil {
+		s.log().Fatal(err)
+	}
+
+	for _, check := range checks {
+		if !check.Passed() {
+			os.Exit(1)
+		}
+	}
+}
 
+func (s *Slingshot) providerInitAction(context *cli.Context) {
+	if context.NArg() < 1 {
+		s.log().Fatal("please provide a directory for the provider")
+	}
+	dir := context.Args().First()
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		s.log().Fatal(err)
+	}
+
+	skeleton := NewProviderSkeleton(
+		filepath.Base(absDir),
+		context.String("type"),
+		context.String("command"),
+	)
+	written, err := skeleton.Write(dir)
+	if err != nil {
+		s.log().Fatal(err)
+	}
+
+	for _, filePath := range written {
+		s.log().Infof("wrote '%s'", filePath)
+	}
+}
+
+// logErrors logs errs grouped by severity and sorted by field path
+func (s *Slingshot) logErrors(errs []error) {
+	for _, valErr := range ValidationErrors(errs) {
+		if valErr.Severity == SeverityWarning {
+			s.log().Warn(valErr)
+		} else {
+			s.log().Error(valErr)
+		}
+	}
+}
+
+func (s *Slingshot) clusterValidateAction(context *cli.Context) {
+	s.Init()
+
+	cName, err := s.readClusterName(context)
+	if err != nil {
+		s.log().Fatal(err)
+	}
+
+	c, err := s.getClusterByName(cName)
+	if err != nil {
+		s.log().Fatal(err)
+	}
+
+	if c.Parameters == nil {
+		s.log().Fatalf("cluster '%s' has no parameters", c.Name)
+	}
+
+	errs := c.Parameters.Validate()
+	failures, _ := SplitWarnings(errs)
+
+	switch output := context.String("output"); output {
+	case "json":
+		if err := WriteValidationJSON(os.Stdout, errs); err != nil {
+			s.log().Fatal(err)
+		}
+	case "table", "":
+		s.logErrors(errs)
+		if len(failures) == 0 {
+			s.log().Infof("parameters of cluster '%s' are valid", c.Name)
+		}
+	default:
+		s.log().Fatalf("unknown output format '%s'", output)
+	}
+
+	if len(failures) > 0 {
+		os.Exit(1)
+	}
 }
 
 func (s *Slingshot) unimplementedAction(context *cli.Context) {
@@ -204,11 +575,35 @@ func (s *Slingshot) clusterCommands() []cli.Command {
 			Flags: []cli.Flag{
 				cli.StringFlag{
 					Name:  "infrastructure-provider, I",
-					Usage: "Image name of the infrastructure provider to use",
+					Usage: "Image name of the infrastructure provider to use (or dir:///path for a local provider directory)",
 				},
 				cli.StringFlag{
 					Name:  "config-provider, C",
-					Usage: "Image name of the config provider to use",
+					Usage: "Image name of the config provider to use (or dir:///path for a local provider directory)",
+				},
+				cli.StringSliceFlag{
+					Name:  "provider",
+					Usage: "Additional stage as name=image, stages are applied in the order given after infrastructure and config (can be repeated)",
+				},
+				cli.StringSliceFlag{
+					Name:  "depends",
+					Usage: "Stages a stage depends on as name=stage[,stage], it is applied after them (can be repeated)",
+				},
+				cli.StringSliceFlag{
+					Name:  "env",
+					Usage: "Source of an environment variable required by a provider as stage.NAME=env:VAR, stage.NAME=file:PATH or stage.NAME=prompt, only the source is stored (can be repeated)",
+				},
+				cli.StringFlag{
+					Name:  "pull-policy",
+					Usage: "Pull policy for provider images of this cluster (always, if-not-present, never)",
+				},
+				cli.StringSliceFlag{
+					Name:  "registry-auth",
+					Usage: "Registry from the docker config whose credentials are used to pull provider images of a registry as registry=auth-registry (default: registry of the image, can be repeated)",
+				},
+				cli.StringSliceFlag{
+					Name:  "parameters, p",
+					Usage: "YAML file with parameters to merge over the defaults (can be repeated, applied in order)",
 				},
 				cli.StringFlag{
 					Name:  "ssh-key, i",
@@ -220,11 +615,151 @@ func (s *Slingshot) clusterCommands() []cli.Command {
 			Name:   "apply",
 			Usage:  "rerun provisioning of existing cluster",
 			Action: s.clusterApplyAction,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "plan",
+					Usage: "Only show the changes the providers would apply",
+				},
+			},
+		},
+		{
+			Name:   "destroy",
+			Usage:  "destroy an existing cluster and remove its local state",
+			Action: s.clusterDestroyAction,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "force, f",
+					Usage: "Remove local state even if a provider failed to destroy the cluster",
+				},
+			},
+		},
+		{
+			Name:   "status",
+			Usage:  "show the status reported by the providers of an existing cluster",
+			Action: s.clusterStatusAction,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "output, o",
+					Value: "table",
+					Usage: "Output format (table, yaml)",
+				},
+			},
+		},
+		{
+			Name:   "kubeconfig",
+			Usage:  "fetch the kubeconfig of an existing cluster",
+			Action: s.clusterKubeconfigAction,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "merge, m",
+					Usage: "Merge the kubeconfig into ~/.kube/config using the cluster name as context",
+				},
+				cli.BoolFlag{
+					Name:  "print, p",
+					Usage: "Print the kubeconfig to stdout",
+				},
+				cli.BoolFlag{
+					Name:  "refresh",
+					Usage: "Run the kubeconfig command of the providers even if a kubeconfig is stored",
+				},
+			},
+		},
+		{
+			Name:      "ssh",
+			Usage:     "connect to a host of an existing cluster",
+			ArgsUsage: "<cluster> [host|role] [command...]",
+			Action:    s.clusterSshAction,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "all, a",
+					Usage: "Run the command on all hosts with the given role",
+				},
+				cli.BoolFlag{
+					Name:  "insecure-ignore-host-key",
+					Usage: "Accept any host key instead of checking it against the inventory and ~/.ssh/known_hosts",
+				},
+			},
+		},
+		{
+			Name:   "edit",
+			Usage:  "edit the parameters of an existing cluster",
+			Action: s.clusterEditAction,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "apply",
+					Usage: "Apply the changes without asking",
+				},
+			},
+		},
+		{
+			Name:   "upgrade-providers",
+			Usage:  "pin the providers of an existing cluster to the latest image digests",
+			Action: s.clusterUpgradeProvidersAction,
+		},
+		{
+			Name:   "validate",
+			Usage:  "validate the parameters of an existing cluster",
+			Action: s.clusterValidateAction,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "output, o",
+					Value: "table",
+					Usage: "Output format (table, json)",
+				},
+			},
 		},
 		{
 			Name:   "list",
 			Usage:  "list existing clusters",
 			Action: s.clusterListAction,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "output, o",
+					Value: "table",
+					Usage: "Output format (table, json, yaml)",
+				},
+			},
+		},
+	}
+}
+
+func (s *Slingshot) providerCommands() []cli.Command {
+	return []cli.Command{
+		{
+			Name:      "init",
+			Usage:     "write the skeleton of a new provider",
+			ArgsUsage: "<dir>",
+			Action:    s.providerInitAction,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "type, t",
+					Usage: "Type of the provider (infrastructure, config or the name of another stage)",
+					Value: "infrastructure",
+				},
+				cli.StringFlag{
+					Name:  "command, c",
+					Usage: "Where the commands of the provider run (host, docker)",
+					Value: "docker",
+				},
+			},
+		},
+		{
+			Name:      "inspect",
+			Usage:     "show the commands a provider offers",
+			ArgsUsage: "<image>",
+			Action:    s.providerInspectAction,
+		},
+		{
+			Name:      "test",
+			Usage:     "check that a provider follows the provider contract",
+			ArgsUsage: "<image>",
+			Action:    s.providerTestAction,
+			Flags: []cli.Flag{
+				cli.StringSliceFlag{
+					Name:  "parameters, p",
+					Usage: "YAML file with parameters to merge over the defaults instead of using the built-in parameters (can be repeated, applied in order)",
+				},
+			},
 		},
 	}
 }
@@ -239,5 +774,10 @@ func (s *Slingshot) Commands() []cli.Command {
 				return nil
 			},
 		},
+		{
+			Name:        "provider",
+			Usage:       "manage providers",
+			Subcommands: s.providerCommands(),
+		},
 	}
 }
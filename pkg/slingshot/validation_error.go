@@ -0,0 +1,111 @@
+package slingshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// ValidationError describes a problem of a parameter at a YAML field path
+type ValidationError struct {
+	Path     string `json:"path"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Path) == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// fieldError returns a validation error for the parameter at path
+func fieldError(path string, format string, args ...interface{}) error {
+	return &ValidationError{
+		Path:     path,
+		Severity: SeverityError,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+// fieldWarning returns a validation warning for the parameter at path
+func fieldWarning(path string, format string, args ...interface{}) error {
+	return &ValidationError{
+		Path:     path,
+		Severity: SeverityWarning,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+// ValidationErrors converts errs to validation errors and sorts them by
+// severity and path
+func ValidationErrors(errs []error) (valErrs []*ValidationError) {
+	for _, err := range errs {
+		valErr, ok := err.(*ValidationError)
+		if !ok {
+			valErr = &ValidationError{
+				Severity: SeverityError,
+				Message:  err.Error(),
+			}
+		}
+		valErrs = append(valErrs, valErr)
+	}
+
+	sort.Stable(validationErrorsBySeverity(valErrs))
+	return
+}
+
+// SplitWarnings separates warnings from errs, the remaining errors should
+// prevent execution
+func SplitWarnings(errs []error) (failures []error, warnings []error) {
+	for _, err := range errs {
+		if valErr, ok := err.(*ValidationError); ok && valErr.Severity == SeverityWarning {
+			warnings = append(warnings, err)
+			continue
+		}
+		failures = append(failures, err)
+	}
+	return
+}
+
+// WriteValidationJSON writes errs as JSON document to out
+func WriteValidationJSON(out io.Writer, errs []error) error {
+	failures, _ := SplitWarnings(errs)
+	valErrs := ValidationErrors(errs)
+	if valErrs == nil {
+		valErrs = []*ValidationError{}
+	}
+
+	doc := struct {
+		Valid  bool               `json:"valid"`
+		Errors []*ValidationError `json:"errors"`
+	}{
+		Valid:  len(failures) == 0,
+		Errors: valErrs,
+	}
+
+	jsonContents, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%s\n", jsonContents)
+	return err
+}
+
+type validationErrorsBySeverity []*ValidationError
+
+func (v validationErrorsBySeverity) Len() int      { return len(v) }
+func (v validationErrorsBySeverity) Swap(i, j int) { v[i], v[j] = v[j], v[i] }
+func (v validationErrorsBySeverity) Less(i, j int) bool {
+	if v[i].Severity != v[j].Severity {
+		return v[i].Severity == SeverityError
+	}
+	return v[i].Path < v[j].Path
+}
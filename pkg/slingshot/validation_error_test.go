@@ -0,0 +1,65 @@
+package slingshot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidationErrorsInventory(t *testing.T) {
+	yamlContent := `inventory:
+  - name: k8s-masters-1
+    roles:
+      - masters
+    privateIP: 192.168.51.51
+  - name: k8s-workers-1
+    roles:
+      - workers
+    privateIP: 192.168.51.52
+  - roles: []
+    publicIP: 192.168.51.300`
+
+	p := &Parameters{}
+	err := p.Parse(yamlContent)
+	assert.Nil(t, err, "Unexpected error during parsing")
+
+	valErrs := ValidationErrors(p.validateInventory())
+
+	assert.Equal(t, []*ValidationError{
+		&ValidationError{Path: "inventory[2].privateIP", Severity: SeverityError, Message: "required field missing"},
+		&ValidationError{Path: "inventory[2].publicIP", Severity: SeverityError, Message: "invalid IP address '192.168.51.300'"},
+		&ValidationError{Path: "inventory[2].roles", Severity: SeverityError, Message: "you need to specify at least one role"},
+		&ValidationError{Path: "inventory[2].name", Severity: SeverityWarning, Message: "host has no name"},
+	}, valErrs)
+
+	failures, warnings := SplitWarnings(p.validateInventory())
+	assert.Equal(t, 3, len(failures))
+	assert.Equal(t, 1, len(warnings))
+}
+
+func TestValidationErrorsJSON(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	err := WriteValidationJSON(buf, []error{
+		fieldWarning("inventory[0].name", "host has no name"),
+		fmt.Errorf("plain error"),
+	})
+	assert.Nil(t, err, "Unexpected error during writing")
+
+	doc := struct {
+		Valid  bool
+		Errors []ValidationError
+	}{}
+	err = json.Unmarshal(buf.Bytes(), &doc)
+	assert.Nil(t, err, "Unexpected error during parsing")
+
+	assert.False(t, doc.Valid)
+	assert.Equal(t, 2, len(doc.Errors))
+	assert.Equal(t, "", doc.Errors[0].Path)
+	assert.Equal(t, SeverityError, doc.Errors[0].Severity)
+	assert.Equal(t, "plain error", doc.Errors[0].Message)
+	assert.Equal(t, SeverityWarning, doc.Errors[1].Severity)
+}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+)
+
+// LineWriter calls a function for every line written to it, carriage
+// returns are treated as line breaks
+type LineWriter struct {
+	buf      bytes.Buffer
+	lineFunc func(line string)
+}
+
+func NewLineWriter(lineFunc func(line string)) *LineWriter {
+	return &LineWriter{
+		lineFunc: lineFunc,
+	}
+}
+
+func (w *LineWriter) Write(p []byte) (n int, err error) {
+	w.buf.Write(p)
+
+	for {
+		pos := bytes.IndexAny(w.buf.Bytes(), "\r\n")
+		if pos < 0 {
+			break
+		}
+		w.writeLine(string(w.buf.Next(pos + 1)))
+	}
+
+	return len(p), nil
+}
+
+// Flush passes on an incomplete last line
+func (w *LineWriter) Flush() {
+	if w.buf.Len() > 0 {
+		w.writeLine(string(w.buf.Next(w.buf.Len())))
+	}
+}
+
+func (w *LineWriter) writeLine(line string) {
+	line = strings.TrimSpace(line)
+	if len(line) > 0 {
+		w.lineFunc(line)
+	}
+}
@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineWriter(t *testing.T) {
+	var lines []string
+	w := NewLineWriter(func(line string) {
+		lines = append(lines, line)
+	})
+
+	fmt.Fprint(w, "Pulling fs layer\r\nDownloading 1/2\rDownloading 2/2\nDone")
+	assert.Equal(t, []string{"Pulling fs layer", "Downloading 1/2", "Downloading 2/2"}, lines)
+
+	w.Flush()
+	assert.Equal(t, []string{"Pulling fs layer", "Downloading 1/2", "Downloading 2/2", "Done"}, lines)
+}
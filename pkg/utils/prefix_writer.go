@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// PrefixWriter prefixes every line written to it before passing it on to
+// the underlying writer
+type PrefixWriter struct {
+	prefix []byte
+	out    io.Writer
+	buf    bytes.Buffer
+	mutex  *sync.Mutex
+}
+
+// NewPrefixWriter returns a PrefixWriter, writers sharing the same mutex
+// never interleave their lines
+func NewPrefixWriter(out io.Writer, prefix string, mutex *sync.Mutex) *PrefixWriter {
+	if mutex == nil {
+		mutex = &sync.Mutex{}
+	}
+	return &PrefixWriter{
+		prefix: []byte(prefix),
+		out:    out,
+		mutex:  mutex,
+	}
+}
+
+func (w *PrefixWriter) Write(p []byte) (n int, err error) {
+	w.buf.Write(p)
+
+	for {
+		pos := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if pos < 0 {
+			break
+		}
+		if err = w.writeLine(w.buf.Next(pos + 1)); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Flush writes out an incomplete last line
+func (w *PrefixWriter) Flush() error {
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	line := append(w.buf.Next(w.buf.Len()), '\n')
+	return w.writeLine(line)
+}
+
+func (w *PrefixWriter) writeLine(line []byte) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if _, err := w.out.Write(w.prefix); err != nil {
+		return err
+	}
+	_, err := w.out.Write(line)
+	return err
+}
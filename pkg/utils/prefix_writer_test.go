@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefixWriter(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := NewPrefixWriter(buf, "host1: ", nil)
+
+	fmt.Fprint(w, "line1\nli")
+	fmt.Fprint(w, "ne2\nline3")
+	assert.Equal(t, "host1: line1\nhost1: line2\n", buf.String())
+
+	err := w.Flush()
+	assert.Nil(t, err, "Unexpected error during flush")
+	assert.Equal(t, "host1: line1\nhost1: line2\nhost1: line3\n", buf.String())
+}
@@ -1,6 +1,9 @@
 package utils
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -94,3 +97,33 @@ func TestWalkDirFromObject(t *testing.T) {
 	}
 	assert.Equal(t, 2, testFiles, "not engough files found")
 }
+
+func TestListTarGz(t *testing.T) {
+	body := []byte("test1")
+	tarData, err := TarListOfObjects([]TarObject{
+		{
+			Header: &tar.Header{Name: "testdir/", Mode: 0750, Typeflag: tar.TypeDir},
+		},
+		{
+			Header: &tar.Header{Name: "testdir/test1.txt", Mode: 0640, Size: int64(len(body))},
+			Body:   &body,
+		},
+	})
+	assert.Nil(t, err, "Error during tar building")
+
+	buf := new(bytes.Buffer)
+	gzipWriter := gzip.NewWriter(buf)
+	gzipWriter.Write(tarData)
+	gzipWriter.Close()
+
+	headers, err := ListTarGz(buf.Bytes())
+	assert.Nil(t, err, "Error during tar listing")
+	if assert.Len(t, headers, 2) {
+		assert.Equal(t, "testdir/", headers[0].Name)
+		assert.Equal(t, "testdir/test1.txt", headers[1].Name)
+		assert.Equal(t, int64(5), headers[1].Size)
+	}
+
+	_, err = ListTarGz([]byte("no archive"))
+	assert.NotNil(t, err)
+}
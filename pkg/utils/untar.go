@@ -73,3 +73,25 @@ func unTarHelper(reader io.Reader, destDir string) error {
 	}
 	return nil
 }
+
+// ListTarGz returns the headers of all entries in a gzipped tar archive
+func ListTarGz(data []byte) (headers []*tar.Header, err error) {
+	reader, err := gzip.NewReader(bytes.NewBuffer(data))
+	if err != nil {
+		return nil, err
+	}
+
+	tarReader := tar.NewReader(reader)
+	for {
+		metaData, err := tarReader.Next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		headers = append(headers, metaData)
+	}
+
+	return headers, nil
+}
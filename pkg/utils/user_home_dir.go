@@ -19,3 +19,11 @@ func VagrantKeyPath() (string, error) {
 	}
 	return path.Join(homeDir, ".vagrant.d/insecure_private_key"), nil
 }
+
+func KubeConfigPath() (string, error) {
+	homeDir, err := UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(homeDir, ".kube/config"), nil
+}
@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// YamlMerge deep merges overlay into base, values in overlay take precedence
+func YamlMerge(base, overlay map[interface{}]interface{}) map[interface{}]interface{} {
+	if base == nil {
+		base = map[interface{}]interface{}{}
+	}
+
+	for key, overlayValue := range overlay {
+		overlayMap, overlayIsMap := overlayValue.(map[interface{}]interface{})
+		baseMap, baseIsMap := base[key].(map[interface{}]interface{})
+		if overlayIsMap && baseIsMap {
+			base[key] = YamlMerge(baseMap, overlayMap)
+		} else {
+			base[key] = overlayValue
+		}
+	}
+
+	return base
+}
+
+// YamlUnknownKeys returns the paths of all keys in value that have no
+// corresponding field in t, following the field naming of yaml.v2
+func YamlUnknownKeys(value interface{}, t reflect.Type, path string) (unknown []string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		valueMap, ok := value.(map[interface{}]interface{})
+		if !ok {
+			return
+		}
+		for key, elem := range valueMap {
+			keyString := fmt.Sprintf("%v", key)
+			field, found := yamlField(t, keyString)
+			if !found {
+				unknown = append(unknown, yamlPath(path, keyString))
+				continue
+			}
+			unknown = append(unknown, YamlUnknownKeys(elem, field.Type, yamlPath(path, keyString))...)
+		}
+
+	case reflect.Map:
+		valueMap, ok := value.(map[interface{}]interface{})
+		if !ok {
+			return
+		}
+		for key, elem := range valueMap {
+			keyString := fmt.Sprintf("%v", key)
+			unknown = append(unknown, YamlUnknownKeys(elem, t.Elem(), yamlPath(path, keyString))...)
+		}
+
+	case reflect.Slice, reflect.Array:
+		valueSlice, ok := value.([]interface{})
+		if !ok {
+			return
+		}
+		for pos, elem := range valueSlice {
+			unknown = append(unknown, YamlUnknownKeys(elem, t.Elem(), fmt.Sprintf("%s[%d]", path, pos))...)
+		}
+	}
+
+	return
+}
+
+func yamlField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		tag := strings.Split(field.Tag.Get("yaml"), ",")
+		name := tag[0]
+		if name == "-" {
+			continue
+		}
+
+		// keys of inlined structs are keys of the outer struct
+		if yamlInline(tag[1:]) {
+			inlineType := field.Type
+			for inlineType.Kind() == reflect.Ptr {
+				inlineType = inlineType.Elem()
+			}
+			if inlineType.Kind() == reflect.Struct {
+				if inlineField, found := yamlField(inlineType, key); found {
+					return inlineField, true
+				}
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		if name == key {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func yamlInline(flags []string) bool {
+	for _, flag := range flags {
+		if flag == "inline" {
+			return true
+		}
+	}
+	return false
+}
+
+func yamlPath(path, key string) string {
+	if len(path) == 0 {
+		return key
+	}
+	return fmt.Sprintf("%s.%s", path, key)
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type yamlTestInline struct {
+	Shared string `yaml:"shared"`
+}
+
+type yamlTestStruct struct {
+	Name   string            `yaml:"name"`
+	Nested *yamlTestStruct   `yaml:"nested,omitempty"`
+	List   []yamlTestStruct  `yaml:"list"`
+	Labels map[string]string `yaml:"labels"`
+	Hidden string            `yaml:"-"`
+	Inline yamlTestInline    `yaml:",inline"`
+}
+
+func TestYamlUnknownKeys(t *testing.T) {
+	value := map[interface{}]interface{}{
+		"name":   "a",
+		"shared": "b",
+		"hidden": "c",
+		"nested": map[interface{}]interface{}{
+			"shared":  "d",
+			"unknown": "e",
+		},
+		"list": []interface{}{
+			map[interface{}]interface{}{"name": "f"},
+			map[interface{}]interface{}{"other": "g"},
+		},
+		"labels": map[interface{}]interface{}{"any": "h"},
+	}
+
+	unknown := YamlUnknownKeys(value, reflect.TypeOf(&yamlTestStruct{}), "")
+	sort.Strings(unknown)
+	assert.Equal(t, []string{"hidden", "list[1].other", "nested.unknown"}, unknown)
+}